@@ -0,0 +1,225 @@
+package key
+
+import (
+	"math/bits"
+)
+
+// Allocation chooses which node on the free list Insert takes when it needs a new one
+//
+type Allocation int
+
+const (
+	// AllocateLast takes the node freed most recently -- the quickest choice, but after a run of deletes the nodes
+	// of a new key end up scattered across the array
+	AllocateLast Allocation = iota
+	// AllocateLowest takes the free node nearest the start of the array, so the active nodes stay packed together
+	AllocateLowest
+	// AllocateNearest takes the free node nearest the node that will point to the new one, so that a key's nodes
+	// stay close together -- it falls back to the lowest free node when there is none within "nearestReach"
+	AllocateNearest
+)
+
+const nearestReach = 64 // words of the free map searched either side of the parent node, 4096 nodes
+
+// the free list is chained through the right pointers of the free nodes, starting at "deletedRoot", whatever the
+// allocation -- for anything other than AllocateLast without trimming, the left pointers chain it backwards as
+// well and a bit map marks which nodes are free, so a node can be taken from anywhere in the list
+
+type freeMap struct {
+	allocation Allocation
+	trim       bool     // free nodes at the end of the array are dropped from it
+	free       []uint64 // one bit for each node
+	lowest     int      // no node below this one is free
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (f *freeMap) set(x int) {
+	for len(f.free) <= x>>6 {
+		f.free = append(f.free, 0)
+	}
+	f.free[x>>6] |= 1 << (x & 63)
+	f.lowest = min(f.lowest, x)
+}
+
+func (f *freeMap) clear(x int) {
+	f.free[x>>6] &^= 1 << (x & 63)
+}
+
+func (f *freeMap) isSet(x int) bool {
+	return x>>6 < len(f.free) && f.free[x>>6]&(1<<(x&63)) != 0
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (f *freeMap) above(x, lastWord int) int {
+	// the first free node at or after "x", looking no further than "lastWord" of the map
+	for w := x >> 6; w <= lastWord && w < len(f.free); w++ {
+		word := f.free[w]
+		if w == x>>6 {
+			word &= ^uint64(0) << (x & 63)
+		}
+		if word != 0 {
+			return w<<6 + bits.TrailingZeros64(word)
+		}
+	}
+	return nullIndexPointer
+}
+
+func (f *freeMap) below(x, firstWord int) int {
+	// the last free node at or before "x", looking no further back than "firstWord" of the map
+	for w := min(x>>6, len(f.free)-1); w >= firstWord && w >= 0; w-- {
+		word := f.free[w]
+		if w == x>>6 {
+			word &= ^uint64(0) >> (63 - x&63)
+		}
+		if word != 0 {
+			return w<<6 + 63 - bits.LeadingZeros64(word)
+		}
+	}
+	return nullIndexPointer
+}
+
+func (f *freeMap) nearest(x int) int {
+	after := f.above(x, x>>6+nearestReach)
+	before := f.below(x, x>>6-nearestReach)
+	if before == nullIndexPointer || (after != nullIndexPointer && after-x < x-before) {
+		return after
+	}
+	return before
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) allocate(node indexNode, nearPointer int) (x int) {
+	// takes a node from the free list, or adds one to the end of the array if the free list is empty -- "nearPointer"
+	// is the node that will point to the new one, or null
+	if indexStructure.deletedRoot == nullIndexPointer {
+		return indexStructure.appendNode(node)
+	}
+	f := indexStructure.freeNodes
+	if f == nil {
+		x = indexStructure.deletedRoot
+		indexStructure.deletedRoot = indexStructure.nodeAt(x).rightPointer
+		indexStructure.setNode(x, node)
+		return
+	}
+	x = nullIndexPointer
+	switch f.allocation {
+	case AllocateLast:
+		x = indexStructure.deletedRoot
+	case AllocateNearest:
+		if nearPointer != nullIndexPointer {
+			x = f.nearest(nearPointer)
+		}
+	}
+	if x == nullIndexPointer {
+		x = f.above(f.lowest, len(f.free))
+		f.lowest = x
+	}
+	indexStructure.unlink(x)
+	indexStructure.setNode(x, node)
+	return
+}
+
+func (indexStructure *Index) unlink(x int) {
+	// takes a node out of a free list that is chained both ways
+	node := indexStructure.nodeAt(x)
+	if node.leftPointer == nullIndexPointer {
+		indexStructure.deletedRoot = node.rightPointer
+	} else {
+		indexStructure.setRightPointer(node.leftPointer, node.rightPointer)
+	}
+	if node.rightPointer != nullIndexPointer {
+		indexStructure.setLeftPointer(node.rightPointer, node.leftPointer)
+	}
+	indexStructure.freeNodes.clear(x)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) free(x int) {
+	indexStructure.freeChain(x, x)
+}
+
+func (indexStructure *Index) freeChain(head, tail int) {
+	// puts the nodes chained by their right pointers from "head" to "tail" on the free list
+	f := indexStructure.freeNodes
+	if f == nil {
+		indexStructure.setRightPointer(tail, indexStructure.deletedRoot)
+		indexStructure.deletedRoot = head
+		return
+	}
+	for x := head; ; {
+		next := indexStructure.nodeAt(x).rightPointer
+		indexStructure.push(x)
+		if x == tail {
+			break
+		}
+		x = next
+	}
+	if f.trim {
+		indexStructure.trim()
+	}
+}
+
+func (indexStructure *Index) push(x int) {
+	indexStructure.setLeftPointer(x, nullIndexPointer)
+	indexStructure.setRightPointer(x, indexStructure.deletedRoot)
+	if indexStructure.deletedRoot != nullIndexPointer {
+		indexStructure.setLeftPointer(indexStructure.deletedRoot, x)
+	}
+	indexStructure.deletedRoot = x
+	indexStructure.freeNodes.set(x)
+}
+
+func (indexStructure *Index) trim() {
+	// drops the free nodes at the end of the array
+	for x := indexStructure.nodeCount() - 1; x >= 0 && indexStructure.freeNodes.isSet(x); x-- {
+		indexStructure.unlink(x)
+		indexStructure.truncateNodes(x)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SetAllocation chooses how the specified index takes nodes from its free list -- with "trim" set, free nodes at
+// the end of the array are dropped from it so that the array shrinks back after deletes
+// an index starts with AllocateLast and no trimming, and Load and Recover keep whatever was chosen
+//
+func SetAllocation(indexStructure *Index, allocation Allocation, trim bool) {
+	if allocation == AllocateLast && !trim {
+		indexStructure.freeNodes = nil
+		return
+	}
+	f := &freeMap{allocation: allocation, trim: trim, lowest: indexStructure.nodeCount()}
+	indexStructure.freeNodes = f
+	previous := nullIndexPointer
+	for x := indexStructure.deletedRoot; x >= 0 && x < indexStructure.nodeCount() && !f.isSet(x); {
+		indexStructure.setLeftPointer(x, previous)
+		f.set(x)
+		previous, x = x, indexStructure.nodeAt(x).rightPointer
+	}
+	if trim {
+		indexStructure.trim()
+	}
+}
+
+// IndexAllocation returns how the specified index takes nodes from its free list and whether it trims the array
+//
+func IndexAllocation(indexStructure *Index) (allocation Allocation, trim bool) {
+	if indexStructure.freeNodes == nil {
+		return AllocateLast, false
+	}
+	return indexStructure.freeNodes.allocation, indexStructure.freeNodes.trim
+}
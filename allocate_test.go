@@ -0,0 +1,160 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var allocations = []Allocation{AllocateLast, AllocateLowest, AllocateNearest}
+
+func freeNodes(indexStructure *Index) (free []int) {
+	for x := indexStructure.deletedRoot; x != nullIndexPointer; x = indexStructure.nodeAt(x).rightPointer {
+		free = append(free, x)
+	}
+	return
+}
+
+func abs(x int) int {
+	return max(x, -x)
+}
+
+func halfDeleted(layout Layout, allocation Allocation, trim bool) (indexStructure *Index, held map[string]int) {
+	// an index of 1000 keys with every other one deleted, so the free list is long and spread across the array
+	indexStructure, held = &Index{}, map[string]int{}
+	InitialiseLayout(indexStructure, layout)
+	SetAllocation(indexStructure, allocation, trim)
+	for x := 0; x < 1000; x++ {
+		Insert(fmt.Sprintf("k%04d", x), x, indexStructure)
+		held[fmt.Sprintf("k%04d", x)] = x
+	}
+	for x := 0; x < 1000; x += 2 {
+		Delete(fmt.Sprintf("k%04d", x), x, indexStructure)
+		delete(held, fmt.Sprintf("k%04d", x))
+	}
+	return
+}
+
+func checkHeld(t *testing.T, indexStructure *Index, held map[string]int) {
+	t.Helper()
+	for keyInput, keyNumber := range held {
+		if _, indexes := Search(keyInput, true, indexStructure); fmt.Sprint(indexes) != fmt.Sprint([]int{keyNumber}) {
+			t.Fatalf("Search(%q) gave %v, want [%d]", keyInput, indexes, keyNumber)
+		}
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestAllocation(t *testing.T) {
+	// each allocation takes the free node it says it does, and leaves the index as it was
+	want := map[Allocation]func(free []int, nearPointer int) int{
+		AllocateLast: func(free []int, nearPointer int) int { return free[0] },
+		AllocateLowest: func(free []int, nearPointer int) (lowest int) {
+			lowest = free[0]
+			for _, x := range free {
+				lowest = min(lowest, x)
+			}
+			return
+		},
+		AllocateNearest: func(free []int, nearPointer int) (nearest int) {
+			nearest = free[0]
+			for _, x := range free {
+				distance, best := abs(x-nearPointer), abs(nearest-nearPointer)
+				if distance < best || (distance == best && x < nearest) {
+					nearest = x
+				}
+			}
+			return
+		},
+	}
+	for _, layout := range benchmarkLayouts {
+		for _, allocation := range allocations {
+			t.Run(fmt.Sprint(layout.name, allocation), func(t *testing.T) {
+				// the policy is changed once the free list is there, so it has to take it over as it stands
+				indexStructure, held := halfDeleted(layout.layout, AllocateLast, false)
+				SetAllocation(indexStructure, allocation, false)
+				if got, trim := IndexAllocation(indexStructure); got != allocation || trim {
+					t.Fatalf("IndexAllocation gave %v %v", got, trim)
+				}
+				r := rand.New(rand.NewSource(1))
+				var taken []int
+				for y := 0; y < 100; y++ {
+					free := freeNodes(indexStructure)
+					nearPointer := r.Intn(indexStructure.nodeCount())
+					want := want[allocation](free, nearPointer)
+					if x := indexStructure.allocate(indexNode{status: 'S', key: 'q'}, nearPointer); x != want {
+						t.Fatalf("allocate near %d took node %d, want %d", nearPointer, x, want)
+					}
+					if len(freeNodes(indexStructure)) != len(free)-1 {
+						t.Fatalf("free list went from %d to %d nodes", len(free), len(freeNodes(indexStructure)))
+					}
+					taken = append(taken, want)
+				}
+				for _, x := range taken {
+					indexStructure.free(x)
+				}
+				checkHeld(t, indexStructure, held)
+			})
+		}
+	}
+}
+
+func TestAllocationReuse(t *testing.T) {
+	// the deleted keys inserted again take back the nodes they freed, whatever the allocation, rather than growing the
+	// array
+	for _, layout := range benchmarkLayouts {
+		for _, allocation := range allocations {
+			indexStructure, held := halfDeleted(layout.layout, allocation, false)
+			nodes, free := indexStructure.nodeCount(), len(freeNodes(indexStructure))
+			if free == 0 {
+				t.Fatalf("%s %v: no nodes were freed", layout.name, allocation)
+			}
+			for x := 0; x < 1000; x += 2 {
+				Insert(fmt.Sprintf("k%04d", x), x, indexStructure)
+				held[fmt.Sprintf("k%04d", x)] = x
+			}
+			if indexStructure.nodeCount() != nodes {
+				t.Errorf("%s %v: %d nodes with %d free, from %d with %d free", layout.name, allocation,
+					indexStructure.nodeCount(), len(freeNodes(indexStructure)), nodes, free)
+			}
+			checkHeld(t, indexStructure, held)
+		}
+	}
+}
+
+func TestAllocationTrim(t *testing.T) {
+	// with trimming the last node of the array is never free, and deleting every key leaves an empty array
+	for _, layout := range benchmarkLayouts {
+		for _, allocation := range allocations {
+			t.Run(fmt.Sprint(layout.name, allocation), func(t *testing.T) {
+				var indexStructure Index
+				InitialiseLayout(&indexStructure, layout.layout)
+				SetAllocation(&indexStructure, allocation, true)
+				r := rand.New(rand.NewSource(3))
+				held := map[string]int{}
+				var keys []string
+				for x := 0; x < 500; x++ {
+					keys = append(keys, fmt.Sprintf("%x", r.Int63()))
+					Insert(keys[x], x, &indexStructure)
+					held[keys[x]] = x
+				}
+				for x := len(keys) - 1; x >= 0; x-- {
+					Delete(keys[x], x, &indexStructure)
+					delete(held, keys[x])
+					if count := indexStructure.nodeCount(); count > 0 && indexStructure.freeNodes.isSet(count-1) {
+						t.Fatalf("last node of the array, %d, is free", count-1)
+					}
+					if x%100 == 0 {
+						checkHeld(t, &indexStructure, held)
+					}
+				}
+				if indexStructure.nodeCount() != 0 || indexStructure.deletedRoot != nullIndexPointer {
+					t.Errorf("%d nodes left, free list at %d", indexStructure.nodeCount(), indexStructure.deletedRoot)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package key
+
+// the characters that can follow a key so far are told apart by 'D' nodes, each sending the characters up to and
+// including its own key left and the rest right -- a level with a few characters keeps them as a chain, but once an
+// Insert leaves a character more than "maxDecisionDepth" 'D' nodes down the level is rebuilt as a balanced tree, so
+// a level holding every byte takes 8 comparisons rather than 255
+// the rebuilt tree uses the same 'D' nodes, each still standing between the same two characters, so the threads of
+// the leaves still point to the right place and nothing outside the level changes
+
+const maxDecisionDepth = 12
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) decisionDepth(keyPointer int) int {
+	node := indexStructure.nodeAt(keyPointer)
+	if node.status != 'D' {
+		return 0
+	}
+	return 1 + max(indexStructure.decisionDepth(node.leftPointer), indexStructure.decisionDepth(node.rightPointer))
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) balance(levelPointer int) {
+	// rebuilds the level below "levelPointer" -- the root of the index when it is null -- as a balanced tree
+	keyPointer := indexStructure.levelRoot(levelPointer)
+	var branches, decisions []int // in order, with decisions[x] standing between branches[x] and branches[x+1]
+	var gather func(keyPointer int)
+	gather = func(keyPointer int) {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status != 'D' {
+			branches = append(branches, keyPointer)
+			return
+		}
+		gather(node.leftPointer)
+		decisions = append(decisions, keyPointer)
+		gather(node.rightPointer)
+	}
+	gather(keyPointer)
+	//
+	var build func(low, high int) int
+	build = func(low, high int) int {
+		if low == high {
+			return branches[low]
+		}
+		middle := (low + high) / 2
+		decision := decisions[middle]
+		leftPointer := build(low, middle)
+		rightPointer := build(middle+1, high)
+		indexStructure.setNode(decision, indexNode{status: 'D', leftPointer: leftPointer,
+			key: indexStructure.nodeAt(branches[middle]).key, rightPointer: rightPointer})
+		if indexStructure.weights != nil {
+			indexStructure.weights.measure(decision, "", indexStructure)
+		}
+		return decision
+	}
+	keyPointer = build(0, len(branches)-1)
+	//
+	if levelPointer == nullIndexPointer {
+		indexStructure.indexRoot = keyPointer
+	} else {
+		indexStructure.setRightPointer(levelPointer, keyPointer)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) balancePath(keyField string, from descent) {
+	// an Insert adds at most one 'D' node, so only the level it went into on the way down to "keyField" from the
+	// level "from" can need rebuilding
+	levelPointer := from.levelPointer
+	keyPointer := indexStructure.levelRoot(from.levelPointer)
+	depth := 0
+	for i := from.i; keyPointer != nullIndexPointer && i < len(keyField); {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			depth++
+			if keyField[i] <= node.key {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		if depth > maxDecisionDepth {
+			indexStructure.balance(levelPointer)
+			return
+		}
+		run := indexStructure.characters(node)
+		if len(keyField)-i < len(run) || keyField[i:i+len(run)] != run || node.status == 'S' || node.status == 'L' {
+			return
+		}
+		levelPointer = keyPointer
+		keyPointer = node.rightPointer
+		depth = 0
+		i += len(run)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) balanceAll(levelPointer int) {
+	// rebuilds every level from the one below "levelPointer" down that has grown too deep -- Insert keeps the levels
+	// it adds to balanced, so this is only for a structure that was not built by Insert, as one read by Load may not be
+	keyPointer := indexStructure.levelRoot(levelPointer)
+	if indexStructure.decisionDepth(keyPointer) > maxDecisionDepth {
+		indexStructure.balance(levelPointer)
+		keyPointer = indexStructure.levelRoot(levelPointer)
+	}
+	var descend func(keyPointer int)
+	descend = func(keyPointer int) {
+		node := indexStructure.nodeAt(keyPointer)
+		switch node.status {
+		case 'D':
+			descend(node.leftPointer)
+			descend(node.rightPointer)
+		case 'X', 'P', 'R', 'K':
+			indexStructure.balanceAll(keyPointer)
+		}
+	}
+	descend(keyPointer)
+}
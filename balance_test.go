@@ -0,0 +1,111 @@
+package key
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func checkLevels(t *testing.T, indexStructure *Index, held map[string]int) {
+	// every key held is found with its number, and neither the root level nor the level below "a" is more than a
+	// few 'D' nodes deep
+	t.Helper()
+	for keyInput, keyNumber := range held {
+		if _, indexes := Search(keyInput, true, indexStructure); fmt.Sprint(indexes) != fmt.Sprint([]int{keyNumber}) {
+			t.Fatalf("Search(%q) gave %v, want [%d]", keyInput, indexes, keyNumber)
+		}
+	}
+	if depth := indexStructure.decisionDepth(indexStructure.indexRoot); depth > maxDecisionDepth+1 {
+		t.Errorf("root level is %d 'D' nodes deep", depth)
+	}
+	if found, keyPointer, _ := locate("a", indexStructure); found {
+		if depth := indexStructure.decisionDepth(indexStructure.nodeAt(keyPointer).rightPointer); depth >
+			maxDecisionDepth+1 {
+			t.Errorf("level below \"a\" is %d 'D' nodes deep", depth)
+		}
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestBalancedLevels(t *testing.T) {
+	// however wide a level grows, and in whatever order, no character is more than a few 'D' nodes down, and deleting
+	// most of them leaves every other key where it was
+	tests := []struct {
+		name  string
+		order func(characters []byte, r *rand.Rand)
+	}{
+		{name: "ascending", order: func([]byte, *rand.Rand) {}},
+		{name: "descending", order: func(characters []byte, r *rand.Rand) {
+			for x, y := 0, len(characters)-1; x < y; x, y = x+1, y-1 {
+				characters[x], characters[y] = characters[y], characters[x]
+			}
+		}},
+		{name: "random", order: func(characters []byte, r *rand.Rand) {
+			r.Shuffle(len(characters), func(x, y int) { characters[x], characters[y] = characters[y], characters[x] })
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var characters []byte
+			for character := 0x21; character <= 0xff; character++ {
+				characters = append(characters, byte(character))
+			}
+			test.order(characters, rand.New(rand.NewSource(1)))
+			var indexStructure Index
+			Initialise(&indexStructure)
+			held := map[string]int{}
+			for x, character := range characters {
+				keyField := string([]byte{character})
+				for _, keyInput := range []string{keyField, "a" + keyField + "z"} {
+					Insert(keyInput, x, &indexStructure)
+					held[keyInput] = x
+				}
+			}
+			checkLevels(t, &indexStructure, held)
+			//
+			for x, character := range characters {
+				if keyField := string([]byte{character}); x%5 != 0 {
+					Delete(keyField, x, &indexStructure)
+					delete(held, keyField)
+				}
+			}
+			checkLevels(t, &indexStructure, held)
+			if valid, faults := Verify(&indexStructure); !valid {
+				t.Fatalf("Verify: %v", faults)
+			}
+		})
+	}
+}
+
+func TestLoadBalances(t *testing.T) {
+	// a structure saved with a level that was never balanced, as one not built by Insert could be, is balanced by Load
+	for _, layout := range benchmarkLayouts {
+		var indexStructure Index
+		InitialiseLayout(&indexStructure, layout.layout)
+		held := map[string]int{}
+		for character := 0x21; character <= 0xff; character++ {
+			keyField := string([]byte{byte(character), 'y'})
+			insertKey(keyField, character, rootDescent, &indexStructure)
+			held[keyField] = character
+		}
+		if depth := indexStructure.decisionDepth(indexStructure.indexRoot); depth < 200 {
+			t.Fatalf("%s: unbalanced level is only %d 'D' nodes deep", layout.name, depth)
+		}
+		var b bytes.Buffer
+		if err := Save(&b, &indexStructure); err != nil {
+			t.Fatal(err)
+		}
+		var loaded Index
+		if err := Load(&b, &loaded); err != nil {
+			t.Fatal(err)
+		}
+		if depth := loaded.decisionDepth(loaded.indexRoot); depth > 8 {
+			t.Errorf("%s: loaded level is %d 'D' nodes deep", layout.name, depth)
+		}
+		checkLevels(t, &loaded, held)
+	}
+}
@@ -0,0 +1,249 @@
+package key
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Op is one change for ApplyBatch
+//
+type Op struct {
+	Delete    bool // remove the key and "index-number" rather than add them
+	Key       string
+	KeyNumber int
+}
+
+// OpResult is what ApplyBatch did with one Op
+//
+type OpResult int
+
+const (
+	OpIgnored  OpResult = iota // the key was blank, or the "index-number" to insert does not fit the compact layout
+	OpInserted                 // the key and "index-number" were added
+	OpExists                   // the key and "index-number" were already in the index
+	OpDeleted                  // the key and "index-number" were removed
+	OpNotFound                 // the key and "index-number" were not in the index to remove
+)
+
+// a descent is where the search for a key has got to at the top of a level -- the characters of the key above the
+// level, the node holding the last of them, and the nodes Delete would cut the key's branch away at
+//
+type descent struct {
+	i                 int
+	levelPointer      int // null at the root of the index
+	deleteIndexNumber int // the last 'D', 'K' or 'R' node above the level
+	linkIndexNumber   int // the node above "deleteIndexNumber"
+	deleteLevel       int // the level "deleteIndexNumber" is in
+	goLeft            bool
+}
+
+var rootDescent = descent{levelPointer: nullIndexPointer, deleteIndexNumber: nullIndexPointer,
+	linkIndexNumber: nullIndexPointer, goLeft: true}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) levelRoot(levelPointer int) int {
+	// the first node of the level below "levelPointer" -- the root of the index when it is null
+	if levelPointer == nullIndexPointer {
+		return indexStructure.indexRoot
+	}
+	return indexStructure.nodeAt(levelPointer).rightPointer
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) descend(keyField string, levels []descent) ([]descent, descent, int) {
+	// carries on down "keyField" from the last of "levels", opening any run whose first character matches, and adds
+	// the top of every level it reaches -- it also returns where it stopped and the node holding the last character
+	// of the key, or null if the key is not there
+	state := levels[len(levels)-1]
+	keyPointer := indexStructure.levelRoot(state.levelPointer)
+	previousIndexNumber := state.levelPointer
+	for i := state.i; keyPointer != nullIndexPointer; {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			state.deleteIndexNumber, state.linkIndexNumber, state.deleteLevel = keyPointer, previousIndexNumber, i
+			previousIndexNumber = keyPointer
+			state.goLeft = keyField[i] <= node.key
+			if state.goLeft {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		if keyField[i] != node.key {
+			break
+		}
+		if node.status == 'P' {
+			indexStructure.open(keyPointer, matching(indexStructure.runs[node.leftPointer], keyField[i:]))
+			node = indexStructure.nodeAt(keyPointer)
+		}
+		if node.status == 'R' || node.status == 'K' {
+			state.deleteIndexNumber, state.linkIndexNumber, state.deleteLevel = keyPointer, previousIndexNumber, i
+		}
+		if i+1 == len(keyField) {
+			return levels, state, keyPointer
+		}
+		if node.status == 'S' || node.status == 'L' {
+			break
+		}
+		previousIndexNumber = keyPointer
+		keyPointer = node.rightPointer
+		i++
+		state.i, state.levelPointer = i, previousIndexNumber
+		levels = append(levels, state)
+	}
+	return levels, state, nullIndexPointer
+}
+
+func (indexStructure *Index) holdsEntry(keyPointer, keyNumber int) bool {
+	// whether the node holding the last character of a key holds "keyNumber" among its "index-numbers"
+	node := indexStructure.nodeAt(keyPointer)
+	switch node.status {
+	case 'R', 'S':
+		return node.leftPointer == keyNumber
+	case 'K', 'L':
+		numberField, _ := decimaliseNumber(keyNumber)
+		keyPointer = node.leftPointer
+		for i := 0; ; {
+			node = indexStructure.nodeAt(keyPointer)
+			if node.status == 'D' {
+				if numberField[i] <= node.key {
+					keyPointer = node.leftPointer
+				} else {
+					keyPointer = node.rightPointer
+				}
+				continue
+			}
+			if numberField[i] != node.key {
+				return false
+			}
+			if i+1 == len(numberField) {
+				return node.status == 'R' || node.status == 'S'
+			}
+			if node.status == 'S' {
+				return false
+			}
+			keyPointer = node.rightPointer
+			i++
+		}
+	}
+	return false
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// ApplyBatch makes a number of Inserts and Deletes on the specified index and returns what each one did, in the
+// order of "ops"
+// the operations are applied in order of key -- those on the same key in the order given -- and each one starts
+// where the one before parted from it rather than at the root, so a batch of neighbouring keys costs far less than
+// the same Inserts and Deletes one at a time
+//
+func ApplyBatch(ops []Op, indexStructure *Index) (results []OpResult) {
+	results = make([]OpResult, len(ops))
+	keyFields := make([]string, len(ops))
+	order := make([]int, 0, len(ops))
+	for x, op := range ops {
+		keyFields[x] = trimKey(op.Key)
+		if len(keyFields[x]) == 0 || (!op.Delete && !indexStructure.holdsNumber(op.KeyNumber)) {
+			results[x] = OpIgnored
+			continue
+		}
+		order = append(order, x)
+	}
+	sort.Slice(order, func(a, b int) bool {
+		if keyFields[order[a]] != keyFields[order[b]] {
+			return keyFields[order[a]] < keyFields[order[b]]
+		}
+		return order[a] < order[b]
+	})
+	//
+	levels := []descent{rootDescent}
+	for n, x := range order {
+		op, keyField := ops[x], keyFields[x]
+		var state descent
+		var keyPointer int
+		levels, state, keyPointer = indexStructure.descend(keyField, levels)
+		from := levels[len(levels)-1]
+		changed := len(levels) - 1 // the levels above this one are just as they were
+		present := keyPointer != nullIndexPointer && indexStructure.holdsEntry(keyPointer, op.KeyNumber)
+		//
+		switch {
+		case op.Delete && !present:
+			results[x] = OpNotFound
+		case op.Delete:
+			if indexStructure.suffixes != nil {
+				indexStructure.suffixes.delete(keyField, op.KeyNumber)
+			}
+			deleteKey(keyField, op.KeyNumber, from, indexStructure)
+			if indexStructure.weights != nil {
+				indexStructure.weights.forget(keyField, op.KeyNumber)
+				indexStructure.weights.refresh(keyField, indexStructure)
+			}
+			if len(indexStructure.observers) > 0 {
+				indexStructure.notifyDelete(keyField, op.KeyNumber)
+			}
+			changed = state.deleteLevel
+			results[x] = OpDeleted
+		case present:
+			results[x] = OpExists
+		default:
+			event := Event{Kind: KeyInserted, Key: keyField, KeyNumber: op.KeyNumber}
+			if keyPointer != nullIndexPointer && indexStructure.nodeAt(keyPointer).status != 'X' {
+				event.Kind = DuplicateAdded
+			}
+			if indexStructure.suffixes != nil {
+				indexStructure.suffixes.insert(keyField, op.KeyNumber)
+			}
+			insertKey(keyField, op.KeyNumber, from, indexStructure)
+			if indexStructure.weights != nil {
+				indexStructure.weights.refresh(keyField, indexStructure)
+			}
+			indexStructure.balancePath(keyField, from)
+			if len(indexStructure.observers) > 0 {
+				indexStructure.notify(event)
+			}
+			results[x] = OpInserted
+		}
+		//
+		// the next key never comes back below the characters it shares with this one, so the runs down there can be
+		// closed now -- the next key starts from the deepest level that is still as it was
+		shared := 0
+		if n+1 < len(order) {
+			next := keyFields[order[n+1]]
+			for shared < len(keyField) && shared < len(next) && keyField[shared] == next[shared] {
+				shared++
+			}
+		}
+		levels = levels[:min(shared, changed)+1]
+		indexStructure.closePath(keyField, levels[len(levels)-1])
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (r OpResult) String() string {
+	switch r {
+	case OpIgnored:
+		return "ignored"
+	case OpInserted:
+		return "inserted"
+	case OpExists:
+		return "exists"
+	case OpDeleted:
+		return "deleted"
+	case OpNotFound:
+		return "not found"
+	}
+	return "OpResult(" + strconv.Itoa(int(r)) + ")"
+}
@@ -0,0 +1,151 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func (m testModel) apply(op Op, indexStructure *Index) OpResult {
+	// what ApplyBatch should do with the Op, made to the model
+	keyField := trimKey(op.Key)
+	entry := keyEntry{key: keyField, keyNumber: op.KeyNumber}
+	switch {
+	case keyField == "" || (!op.Delete && !indexStructure.holdsNumber(op.KeyNumber)):
+		return OpIgnored
+	case op.Delete && m[entry]:
+		delete(m, entry)
+		return OpDeleted
+	case op.Delete:
+		return OpNotFound
+	case m[entry]:
+		return OpExists
+	}
+	m[entry] = true
+	return OpInserted
+}
+
+func oneAtATime(ops []Op, indexStructure *Index) (results []OpResult) {
+	// the Ops made by Insert and Delete in the order ApplyBatch takes them, and what each one did as Search sees it
+	results = make([]OpResult, len(ops))
+	order := make([]int, len(ops))
+	for x := range order {
+		order[x] = x
+	}
+	sort.SliceStable(order, func(a, b int) bool { return trimKey(ops[order[a]].Key) < trimKey(ops[order[b]].Key) })
+	for _, x := range order {
+		op := ops[x]
+		_, indexes := Search(op.Key, true, indexStructure)
+		held := false
+		for _, keyNumber := range indexes {
+			held = held || keyNumber == op.KeyNumber
+		}
+		switch {
+		case trimKey(op.Key) == "" || (!op.Delete && !indexStructure.holdsNumber(op.KeyNumber)):
+			results[x] = OpIgnored
+		case op.Delete && held:
+			Delete(op.Key, op.KeyNumber, indexStructure)
+			results[x] = OpDeleted
+		case op.Delete:
+			results[x] = OpNotFound
+		case held:
+			results[x] = OpExists
+		default:
+			Insert(op.Key, op.KeyNumber, indexStructure)
+			results[x] = OpInserted
+		}
+	}
+	return
+}
+
+func heldText(indexStructure *Index) (text string) {
+	// every key the index holds with its numbers, in key order
+	walk(indexStructure.indexRoot, nil, indexStructure, func([]byte) (bool, bool) { return true, false },
+		func(keyPath []byte, keyNumbers []int) { text += fmt.Sprintf("%q %v\n", keyPath, keyNumbers) })
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestApplyBatch(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Op
+		want []OpResult
+	}{
+		{name: "empty"},
+		{name: "insert", ops: []Op{{Key: "b", KeyNumber: 1}, {Key: "a", KeyNumber: 2}, {Key: "ab", KeyNumber: 3}},
+			want: []OpResult{OpInserted, OpInserted, OpInserted}},
+		{name: "same key in order", ops: []Op{{Key: "a", KeyNumber: 1}, {Delete: true, Key: "a", KeyNumber: 1},
+			{Key: "a", KeyNumber: 1}, {Key: "a", KeyNumber: 1}},
+			want: []OpResult{OpInserted, OpDeleted, OpInserted, OpExists}},
+		{name: "not found", ops: []Op{{Delete: true, Key: "a", KeyNumber: 1}, {Key: "a", KeyNumber: 1},
+			{Delete: true, Key: "a", KeyNumber: 2}, {Delete: true, Key: "ab", KeyNumber: 1}},
+			want: []OpResult{OpNotFound, OpInserted, OpNotFound, OpNotFound}},
+		{name: "blank", ops: []Op{{Key: "  ", KeyNumber: 1}, {Delete: true, Key: "", KeyNumber: 1}},
+			want: []OpResult{OpIgnored, OpIgnored}},
+		{name: "trimmed", ops: []Op{{Key: " a ", KeyNumber: 1}, {Delete: true, Key: "a", KeyNumber: 1}},
+			want: []OpResult{OpInserted, OpDeleted}},
+		{name: "duplicates", ops: []Op{{Key: "k", KeyNumber: 20}, {Key: "k", KeyNumber: 22},
+			{Key: "k", KeyNumber: 2}, {Delete: true, Key: "k", KeyNumber: 20}, {Delete: true, Key: "k", KeyNumber: 2}},
+			want: []OpResult{OpInserted, OpInserted, OpInserted, OpDeleted, OpDeleted}},
+		{name: "neighbours in a run", ops: []Op{{Key: "http://example.com/a", KeyNumber: 1},
+			{Key: "http://example.com/b", KeyNumber: 2}, {Key: "http://example.com/", KeyNumber: 3},
+			{Delete: true, Key: "http://example.com/a", KeyNumber: 1}, {Key: "http://example.org", KeyNumber: 4}},
+			want: []OpResult{OpInserted, OpInserted, OpInserted, OpDeleted, OpInserted}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var batched, single Index
+			Initialise(&batched)
+			Initialise(&single)
+			if results := ApplyBatch(test.ops, &batched); fmt.Sprint(results) != fmt.Sprint(test.want) {
+				t.Errorf("ApplyBatch gave %v, want %v", results, test.want)
+			}
+			oneAtATime(test.ops, &single)
+			if heldText(&batched) != heldText(&single) {
+				t.Errorf("ApplyBatch left\n%sInsert and Delete one at a time\n%s", heldText(&batched),
+					heldText(&single))
+			}
+			if valid, faults := Verify(&batched); !valid {
+				t.Errorf("Verify: %v", faults)
+			}
+		})
+	}
+}
+
+func TestApplyBatchOneAtATime(t *testing.T) {
+	// random batches of neighbouring keys, on an index that already holds some, report and leave the same keys as the
+	// same Ops made one at a time by Insert and Delete, in either layout
+	for _, layout := range benchmarkLayouts {
+		for seed := int64(0); seed < 20; seed++ {
+			r := rand.New(rand.NewSource(seed))
+			var batched, single Index
+			InitialiseLayout(&batched, layout.layout)
+			InitialiseLayout(&single, layout.layout)
+			randomOp := func() Op {
+				return Op{Delete: r.Intn(3) == 0, Key: "user/" + randomKey(r, "ab ", 6), KeyNumber: r.Intn(12) - 2}
+			}
+			for batch := 0; batch < 10; batch++ {
+				ops := make([]Op, r.Intn(60))
+				for x := range ops {
+					ops[x] = randomOp()
+				}
+				results := ApplyBatch(ops, &batched)
+				if want := oneAtATime(ops, &single); fmt.Sprint(results) != fmt.Sprint(want) {
+					t.Fatalf("%s seed %d: ApplyBatch gave %v, want %v", layout.name, seed, results, want)
+				}
+				if heldText(&batched) != heldText(&single) {
+					t.Fatalf("%s seed %d: ApplyBatch left\n%sInsert and Delete one at a time\n%s", layout.name, seed,
+						heldText(&batched), heldText(&single))
+				}
+			}
+			if valid, faults := Verify(&batched); !valid {
+				t.Fatalf("%s seed %d: Verify: %v", layout.name, seed, faults)
+			}
+		}
+	}
+}
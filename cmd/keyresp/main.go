@@ -0,0 +1,58 @@
+// Command keyresp serves an index over the Redis protocol -- see package keyresp for the commands it answers
+//
+//	keyresp [-addr host:port] [index-file]
+//
+// the index file, written by Save or "keytool build", is loaded and served, otherwise an empty index is served --
+// nothing is written back to the file
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/apwoodhouse/key"
+	"github.com/apwoodhouse/key/keyresp"
+)
+
+func main() {
+	addr := flag.String("addr", ":6380", "address to listen on")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: keyresp [-addr host:port] [index-file]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	//
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	if flag.NArg() == 1 {
+		if err := load(flag.Arg(0), &indexStructure); err != nil {
+			fmt.Fprintln(os.Stderr, "keyresp:", err)
+			os.Exit(1)
+		}
+	}
+	listener, err := net.Listen("tcp", *addr)
+	if err == nil {
+		err = keyresp.New(&indexStructure).Serve(listener)
+	}
+	fmt.Fprintln(os.Stderr, "keyresp:", err)
+	os.Exit(1)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func load(fileName string, indexStructure *key.Index) (err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	return key.Load(file, indexStructure)
+}
@@ -0,0 +1,61 @@
+// Command keyserver serves named indexes over HTTP -- see package keyserver for the requests it answers
+//
+//	keyserver [-addr host:port] [name=index-file ...]
+//
+// each index file, written by Save or "keytool build", is loaded and served under its name -- more indexes can be
+// made empty with PUT /indexes/{name}, and nothing is written back to the files
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/apwoodhouse/key"
+	"github.com/apwoodhouse/key/keyserver"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: keyserver [-addr host:port] [name=index-file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	//
+	server := keyserver.New()
+	for _, arg := range flag.Args() {
+		name, fileName, found := strings.Cut(arg, "=")
+		if !found || name == "" || strings.Contains(name, "/") {
+			flag.Usage()
+			os.Exit(2)
+		}
+		indexStructure, err := load(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keyserver:", err)
+			os.Exit(1)
+		}
+		server.Add(name, indexStructure)
+	}
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		fmt.Fprintln(os.Stderr, "keyserver:", err)
+		os.Exit(1)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func load(fileName string) (indexStructure *key.Index, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	indexStructure = &key.Index{}
+	err = key.Load(file, indexStructure)
+	return
+}
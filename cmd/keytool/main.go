@@ -0,0 +1,326 @@
+// Command keytool builds, queries, checks and dumps saved index files
+//
+//	keytool build [-tsv] [-header] [-column n] [-field name] -o index-file data-file
+//	keytool search [-prefix] index-file key
+//	keytool range index-file low-key high-key
+//	keytool delete index-file key index-number
+//	keytool stats index-file
+//	keytool verify index-file
+//	keytool dump [-dot] [-prefix key] index-file
+//
+// "build" files the chosen column of every data row under the row number, counting from 0 after any header
+// "delete" fails, leaving the file as it was, if the key is not filed under the index-number
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/apwoodhouse/key"
+)
+
+var commands = map[string]func(args []string) error{
+	"build":  build,
+	"search": search,
+	"range":  searchRange,
+	"delete": remove,
+	"stats":  stats,
+	"verify": verify,
+	"dump":   dump,
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: keytool build|search|range|delete|stats|verify|dump [flags] index-file ...")
+		os.Exit(2)
+	}
+	if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "keytool "+os.Args[1]+":", err)
+		os.Exit(1)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func parse(flags *flag.FlagSet, args []string, operands int, usage string) error {
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: keytool "+flags.Name()+" "+usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != operands {
+		flags.Usage()
+		return errors.New("wrong number of arguments")
+	}
+	return nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func load(fileName string) (indexStructure key.Index, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	err = key.Load(file, &indexStructure)
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func save(fileName string, indexStructure *key.Index) (err error) {
+	// written to a temporary file first so a failed save never leaves a half-written index behind
+	file, err := os.CreateTemp(filepath.Dir(fileName), ".keytool-*")
+	if err != nil {
+		return
+	}
+	if err = key.Save(file, indexStructure); err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fileName)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func printIndexes(indexes []int) {
+	for _, keyNumber := range indexes {
+		fmt.Println(keyNumber)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func build(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	tsv := flags.Bool("tsv", false, "data file is tab separated rather than comma separated")
+	header := flags.Bool("header", false, "first row of the data file holds the column names")
+	column := flags.Int("column", 1, "number of the column to index, counting from 1")
+	field := flags.String("field", "", "name of the column to index, taken from the header row")
+	output := flags.String("o", "", "index file to write")
+	if err := parse(flags, args, 1, "[-tsv] [-header] [-column n] [-field name] -o index-file data-file"); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("no index file given with -o")
+	}
+	if *field != "" && !*header {
+		return errors.New("-field needs -header")
+	}
+	//
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	if *tsv {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+	//
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	columnNumber := *column - 1
+	for row := -1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if row == -1 && *header {
+			if *field != "" {
+				columnNumber = -1
+				for i, name := range record {
+					if name == *field {
+						columnNumber = i
+					}
+				}
+				if columnNumber == -1 {
+					return fmt.Errorf("no column named %q", *field)
+				}
+			}
+			continue
+		}
+		if row == -1 {
+			row = 0
+		}
+		if columnNumber < 0 || columnNumber >= len(record) {
+			return fmt.Errorf("row %d has no column %d", row, columnNumber+1)
+		}
+		key.Insert(record[columnNumber], row, &indexStructure)
+	}
+	return save(*output, &indexStructure)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func search(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	prefix := flags.Bool("prefix", false, "return every key starting with the given key")
+	if err := parse(flags, args, 2, "[-prefix] index-file key"); err != nil {
+		return err
+	}
+	indexStructure, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, indexes := key.Search(flags.Arg(1), !*prefix, &indexStructure)
+	printIndexes(indexes)
+	return nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func searchRange(args []string) error {
+	flags := flag.NewFlagSet("range", flag.ContinueOnError)
+	if err := parse(flags, args, 3, "index-file low-key high-key"); err != nil {
+		return err
+	}
+	indexStructure, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, indexes := key.SearchRange(flags.Arg(1), flags.Arg(2), &indexStructure)
+	printIndexes(indexes)
+	return nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func remove(args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	if err := parse(flags, args, 3, "index-file key index-number"); err != nil {
+		return err
+	}
+	keyNumber, err := strconv.Atoi(flags.Arg(2))
+	if err != nil {
+		return err
+	}
+	indexStructure, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, indexes := key.Search(flags.Arg(1), true, &indexStructure)
+	if !slices.Contains(indexes, keyNumber) {
+		return fmt.Errorf("%q %d is not in the index", flags.Arg(1), keyNumber)
+	}
+	key.Delete(flags.Arg(1), keyNumber, &indexStructure)
+	return save(flags.Arg(0), &indexStructure)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func stats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	if err := parse(flags, args, 1, "index-file"); err != nil {
+		return err
+	}
+	indexStructure, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	result := key.Statistics(&indexStructure)
+	fmt.Printf("nodes active %d deleted %d\n", result.Active, result.Deleted)
+	fmt.Printf("nodes R %d S %d X %d K %d L %d D %d P %d\n",
+		result.NodeR, result.NodeS, result.NodeX, result.NodeK, result.NodeL, result.NodeD, result.NodeP)
+	fmt.Printf("keys %d entries %d largest duplicate %d\n", result.Keys, result.Entries, result.LargestDuplicate)
+	fmt.Printf("key length average %.2f maximum %d\n", result.AverageKeyLength, result.MaximumKeyLength)
+	fmt.Printf("bytes in use %d free %d\n", result.BytesInUse, result.BytesFree)
+	fmt.Printf("traversal depth %d\n", result.Depth)
+	for level, chain := range result.DecisionChain {
+		fmt.Printf("decision chain at character %d %.2f\n", level+1, chain)
+	}
+	for depth, count := range result.DepthHistogram {
+		if count > 0 {
+			fmt.Printf("keys at depth %d %d\n", depth, count)
+		}
+	}
+	return nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	if err := parse(flags, args, 1, "index-file"); err != nil {
+		return err
+	}
+	indexStructure, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	valid, faults := key.Verify(&indexStructure)
+	for _, fault := range faults {
+		fmt.Println(fault)
+	}
+	if !valid {
+		return fmt.Errorf("%d faults found", len(faults))
+	}
+	return nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func dump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	dot := flags.Bool("dot", false, "write a Graphviz DOT graph rather than the raw node array")
+	prefix := flags.String("prefix", "", "only draw the branch below this key (with -dot)")
+	if err := parse(flags, args, 1, "[-dot] [-prefix key] index-file"); err != nil {
+		return err
+	}
+	indexStructure, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *dot {
+		return key.WriteDOT(os.Stdout, *prefix, &indexStructure)
+	}
+	return key.WriteNodes(os.Stdout, &indexStructure)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apwoodhouse/key"
+)
+
+func TestDelete(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "index")
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	key.Insert("alpha", 1, &indexStructure)
+	key.Insert("alpha", 2, &indexStructure)
+	key.Insert("beta", 3, &indexStructure)
+	if err := save(fileName, &indexStructure); err != nil {
+		t.Fatal(err)
+	}
+	//
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+		want    []int // what "alpha" is filed under afterwards
+	}{
+		{name: "absent number", args: []string{fileName, "alpha", "3"}, wantErr: true, want: []int{1, 2}},
+		{name: "absent key", args: []string{fileName, "gamma", "1"}, wantErr: true, want: []int{1, 2}},
+		{name: "prefix of a key", args: []string{fileName, "alp", "1"}, wantErr: true, want: []int{1, 2}},
+		{name: "bad number", args: []string{fileName, "alpha", "one"}, wantErr: true, want: []int{1, 2}},
+		{name: "present", args: []string{fileName, "alpha", "1"}, want: []int{2}},
+		{name: "already deleted", args: []string{fileName, "alpha", "1"}, wantErr: true, want: []int{2}},
+	}
+	for _, test := range tests {
+		before, _ := os.ReadFile(fileName)
+		err := remove(test.args)
+		if (err != nil) != test.wantErr {
+			t.Fatalf("%s: delete gave %v", test.name, err)
+		}
+		if after, _ := os.ReadFile(fileName); err != nil && string(after) != string(before) {
+			t.Fatalf("%s: failed delete changed the file", test.name)
+		}
+		loaded, err := load(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, indexes := key.Search("alpha", true, &loaded); fmt.Sprint(indexes) != fmt.Sprint(test.want) {
+			t.Fatalf("%s: alpha is filed under %v, want %v", test.name, indexes, test.want)
+		}
+	}
+}
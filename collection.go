@@ -0,0 +1,179 @@
+package key
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Collection holds an array of records along with any number of named indexes over it -- the index number of a
+// record is its position in the array, and every index is kept up to date as records are added, updated and removed
+// the position of a removed record is given to the next record added, as nodes on the free list are
+//
+type Collection[T any] struct {
+	record   []T
+	active   []bool
+	freeList []int
+	indexes  map[string]*collectionIndex[T]
+}
+
+type collectionIndex[T any] struct {
+	extract   func(record T) string
+	structure Index
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// NewCollection returns an empty collection with no indexes
+//
+func NewCollection[T any]() *Collection[T] {
+	return &Collection[T]{indexes: make(map[string]*collectionIndex[T])}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// AddIndex defines a named index whose key for each record is returned by "extract" and files every record already
+// held -- a record whose key is blank is left out of that index, and an index of the same name is replaced
+//
+func (c *Collection[T]) AddIndex(name string, extract func(record T) string) {
+	ci := &collectionIndex[T]{extract: extract}
+	Initialise(&ci.structure)
+	for keyNumber, record := range c.record {
+		if c.active[keyNumber] {
+			Insert(extract(record), keyNumber, &ci.structure)
+		}
+	}
+	c.indexes[name] = ci
+}
+
+// RemoveIndex forgets a named index
+//
+func (c *Collection[T]) RemoveIndex(name string) {
+	delete(c.indexes, name)
+}
+
+// Index returns a named index, or nil if there is none, for use with any of the search functions -- it must not be
+// changed other than through the collection
+//
+func (c *Collection[T]) Index(name string) *Index {
+	if ci, exists := c.indexes[name]; exists {
+		return &ci.structure
+	}
+	return nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Add stores a record, files it in every index and returns its index number
+//
+func (c *Collection[T]) Add(record T) (keyNumber int) {
+	if len(c.freeList) > 0 {
+		keyNumber = c.freeList[len(c.freeList)-1]
+		c.freeList = c.freeList[:len(c.freeList)-1]
+		c.record[keyNumber] = record
+		c.active[keyNumber] = true
+	} else {
+		keyNumber = len(c.record)
+		c.record = append(c.record, record)
+		c.active = append(c.active, true)
+	}
+	for _, ci := range c.indexes {
+		Insert(ci.extract(record), keyNumber, &ci.structure)
+	}
+	return
+}
+
+// Update replaces the record at an index number and moves it in every index whose key for it has changed
+// "updated" is "false" if there is no record at that index number
+//
+func (c *Collection[T]) Update(keyNumber int, record T) (updated bool) {
+	if !c.holds(keyNumber) {
+		return
+	}
+	for _, ci := range c.indexes {
+		oldKey, newKey := ci.extract(c.record[keyNumber]), ci.extract(record)
+		if trimKey(oldKey) != trimKey(newKey) {
+			Delete(oldKey, keyNumber, &ci.structure)
+			Insert(newKey, keyNumber, &ci.structure)
+		}
+	}
+	c.record[keyNumber] = record
+	updated = true
+	return
+}
+
+// Remove takes the record at an index number out of every index and frees its position
+// "removed" is "false" if there is no record at that index number
+//
+func (c *Collection[T]) Remove(keyNumber int) (removed bool) {
+	if !c.holds(keyNumber) {
+		return
+	}
+	for _, ci := range c.indexes {
+		Delete(ci.extract(c.record[keyNumber]), keyNumber, &ci.structure)
+	}
+	var empty T
+	c.record[keyNumber] = empty
+	c.active[keyNumber] = false
+	c.freeList = append(c.freeList, keyNumber)
+	removed = true
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Get returns the record at an index number
+// "found" is "false" if there is no record at that index number
+//
+func (c *Collection[T]) Get(keyNumber int) (record T, found bool) {
+	if c.holds(keyNumber) {
+		record, found = c.record[keyNumber], true
+	}
+	return
+}
+
+// Len returns the number of records held
+//
+func (c *Collection[T]) Len() int {
+	return len(c.record) - len(c.freeList)
+}
+
+// Records returns the records at a list of index numbers, as returned by a search, in the same order
+//
+func (c *Collection[T]) Records(indexes []int) (records []T) {
+	for _, keyNumber := range indexes {
+		if c.holds(keyNumber) {
+			records = append(records, c.record[keyNumber])
+		}
+	}
+	return
+}
+
+// Search runs Search on a named index and returns the records found, in ascending key order
+// "matchFound" is "true" if something is located -- an unknown index name locates nothing
+//
+func (c *Collection[T]) Search(name, keyInput string, searchPrecisely bool) (matchFound bool, records []T) {
+	ci, exists := c.indexes[name]
+	if !exists {
+		return
+	}
+	var indexes []int
+	if matchFound, indexes = Search(keyInput, searchPrecisely, &ci.structure); matchFound {
+		records = c.Records(indexes)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (c *Collection[T]) holds(keyNumber int) bool {
+	return keyNumber >= 0 && keyNumber < len(c.record) && c.active[keyNumber]
+}
@@ -0,0 +1,139 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+type testRecord struct {
+	name, city string
+}
+
+var testExtracts = map[string]func(record testRecord) string{
+	"name":    func(record testRecord) string { return record.name },
+	"city":    func(record testRecord) string { return record.city },
+	"initial": func(record testRecord) string { return record.name[:min(1, len(record.name))] },
+}
+
+func checkCollection(t *testing.T, c *Collection[testRecord], held map[int]testRecord) {
+	// every named index holds exactly the key of each record held under its number, and every record can be had back
+	t.Helper()
+	if c.Len() != len(held) {
+		t.Fatalf("Len gave %d, want %d", c.Len(), len(held))
+	}
+	for keyNumber, record := range held {
+		if got, found := c.Get(keyNumber); !found || got != record {
+			t.Fatalf("Get(%d) gave %v %v, want %v", keyNumber, got, found, record)
+		}
+	}
+	for name, extract := range testExtracts {
+		m := testModel{}
+		for keyNumber, record := range held {
+			m.insert(extract(record), keyNumber)
+		}
+		checkIndex(t, c.Index(name), m)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestCollection(t *testing.T) {
+	c := NewCollection[testRecord]()
+	c.AddIndex("name", testExtracts["name"])
+	c.AddIndex("city", testExtracts["city"])
+	held := map[int]testRecord{}
+	for _, record := range []testRecord{{"ann", "york"}, {"bob", "leeds"}, {"cat", "york"}, {"", "hull"}} {
+		held[c.Add(record)] = record
+	}
+	// an index added later files the records already held, and a blank key is left out
+	c.AddIndex("initial", testExtracts["initial"])
+	checkCollection(t, c, held)
+	if matchFound, records := c.Search("city", "york", true); !matchFound ||
+		fmt.Sprint(records) != fmt.Sprint([]testRecord{{"ann", "york"}, {"cat", "york"}}) {
+		t.Errorf("Search(city, york) gave %v %v", matchFound, records)
+	}
+	// an update moves a record only in the indexes whose key for it has changed
+	if !c.Update(1, testRecord{"bob", "york"}) {
+		t.Fatal("Update(1) gave false")
+	}
+	held[1] = testRecord{"bob", "york"}
+	checkCollection(t, c, held)
+	// a removed record's number is given to the next record added
+	if !c.Remove(0) {
+		t.Fatal("Remove(0) gave false")
+	}
+	delete(held, 0)
+	checkCollection(t, c, held)
+	if keyNumber := c.Add(testRecord{"dan", "hull"}); keyNumber != 0 {
+		t.Errorf("Add gave %d, want the freed 0", keyNumber)
+	}
+	held[0] = testRecord{"dan", "hull"}
+	checkCollection(t, c, held)
+	// numbers that hold no record
+	for _, keyNumber := range []int{-1, 4, 99} {
+		if c.Update(keyNumber, testRecord{"x", "y"}) || c.Remove(keyNumber) {
+			t.Errorf("Update or Remove(%d) gave true", keyNumber)
+		}
+		if _, found := c.Get(keyNumber); found {
+			t.Errorf("Get(%d) found a record", keyNumber)
+		}
+	}
+	c.Remove(3)
+	delete(held, 3)
+	records := c.Records([]int{2, 3, 0})
+	if fmt.Sprint(records) != fmt.Sprint([]testRecord{{"cat", "york"}, {"dan", "hull"}}) {
+		t.Errorf("Records gave %v", records)
+	}
+	checkCollection(t, c, held)
+	// an index that is removed, or was never added, finds nothing
+	c.RemoveIndex("city")
+	if c.Index("city") != nil || c.Index("street") != nil {
+		t.Error("Index gave an index that is not there")
+	}
+	if matchFound, records := c.Search("city", "york", true); matchFound || records != nil {
+		t.Errorf("Search on a removed index gave %v %v", matchFound, records)
+	}
+}
+
+func TestCollectionAgainstModel(t *testing.T) {
+	// random adds, updates and removes keep every named index holding what the records say
+	r := rand.New(rand.NewSource(1))
+	c := NewCollection[testRecord]()
+	for name, extract := range testExtracts {
+		c.AddIndex(name, extract)
+	}
+	held := map[int]testRecord{}
+	randomRecord := func() testRecord {
+		return testRecord{name: randomKey(r, "ab ", 4), city: randomKey(r, "xy", 3)}
+	}
+	for x := 0; x < 1000; x++ {
+		keyNumber := r.Intn(c.Len() + 5)
+		switch r.Intn(3) {
+		case 0:
+			record := randomRecord()
+			held[c.Add(record)] = record
+		case 1:
+			record := randomRecord()
+			_, holds := held[keyNumber]
+			if c.Update(keyNumber, record) != holds {
+				t.Fatalf("Update(%d) gave %v", keyNumber, !holds)
+			}
+			if holds {
+				held[keyNumber] = record
+			}
+		case 2:
+			_, holds := held[keyNumber]
+			if c.Remove(keyNumber) != holds {
+				t.Fatalf("Remove(%d) gave %v", keyNumber, !holds)
+			}
+			delete(held, keyNumber)
+		}
+		if x%20 == 0 {
+			checkCollection(t, c, held)
+		}
+	}
+	checkCollection(t, c, held)
+}
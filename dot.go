@@ -0,0 +1,166 @@
+package key
+
+import (
+	"fmt"
+	"io"
+)
+
+type dotWriter struct {
+	w              io.Writer
+	err            error
+	indexStructure *Index
+	shown          map[int]bool
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (d *dotWriter) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func dotCharacter(character byte) string {
+	// characters that have a meaning inside a record label are escaped, anything unprintable is shown in hex
+	switch {
+	case character < ' ' || character > '~':
+		return fmt.Sprintf("0x%02x", character)
+	case character == '"' || character == '\\' || character == '{' || character == '}' ||
+		character == '|' || character == '<' || character == '>' || character == ' ':
+		return `\` + string(character)
+	}
+	return string(character)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (d *dotWriter) collect(keyPointer int) {
+	// marks every node below "keyPointer" so that threads are only drawn when they land inside the picture
+	if keyPointer == nullIndexPointer || d.shown[keyPointer] {
+		return
+	}
+	d.shown[keyPointer] = true
+	node := d.indexStructure.nodeAt(keyPointer)
+	if node.status == 'D' || node.status == 'K' || node.status == 'L' {
+		d.collect(node.leftPointer)
+	}
+	if node.status == 'D' || node.status == 'X' || node.status == 'P' || node.status == 'R' || node.status == 'K' {
+		d.collect(node.rightPointer)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (d *dotWriter) render(keyPointer int, duplicate bool) {
+	node := d.indexStructure.nodeAt(keyPointer)
+	colour := "white"
+	if duplicate {
+		colour = "lightblue"
+	}
+	switch node.status {
+	case 'R', 'S':
+		d.printf("\tn%d [label=\"{%d|%c|%s|#%d}\", fillcolor=%s];\n",
+			keyPointer, keyPointer, node.status, dotCharacter(node.key), node.leftPointer, colour)
+	case 'P':
+		var run string
+		for _, character := range []byte(d.indexStructure.runs[node.leftPointer]) {
+			run += dotCharacter(character)
+		}
+		d.printf("\tn%d [label=\"{%d|%c|%s}\", fillcolor=%s];\n", keyPointer, keyPointer, node.status, run, colour)
+	default:
+		d.printf("\tn%d [label=\"{%d|%c|%s}\", fillcolor=%s];\n",
+			keyPointer, keyPointer, node.status, dotCharacter(node.key), colour)
+	}
+	//
+	switch node.status {
+	//
+	case 'D':
+		d.printf("\tn%d -> n%d [label=\"<=\"];\n", keyPointer, node.leftPointer)
+		d.printf("\tn%d -> n%d [label=\">\"];\n", keyPointer, node.rightPointer)
+		d.render(node.leftPointer, duplicate)
+		d.render(node.rightPointer, duplicate)
+	//
+	case 'X', 'P', 'R':
+		d.printf("\tn%d -> n%d;\n", keyPointer, node.rightPointer)
+		d.render(node.rightPointer, duplicate)
+	//
+	case 'K':
+		d.printf("\tn%d -> n%d [style=bold, color=blue, label=\"dup\"];\n", keyPointer, node.leftPointer)
+		d.printf("\tn%d -> n%d;\n", keyPointer, node.rightPointer)
+		d.render(node.leftPointer, true)
+		d.render(node.rightPointer, duplicate)
+	//
+	case 'L':
+		d.printf("\tn%d -> n%d [style=bold, color=blue, label=\"dup\"];\n", keyPointer, node.leftPointer)
+		d.render(node.leftPointer, true)
+	}
+	//
+	if (node.status == 'S' || node.status == 'L') && d.shown[node.rightPointer] {
+		d.printf("\tn%d -> n%d [style=dashed, color=grey, constraint=false];\n", keyPointer, node.rightPointer)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// WriteDOT writes the node structure of the specified index to "w" as a Graphviz DOT graph
+// each node shows its position in the node array, its status, its character and, for keys, its "index-number"
+// threads are drawn dashed and duplicate branches are drawn bold and shaded
+// a blank "keyPrefix" draws the whole index, otherwise only the branch below the prefix is drawn
+//
+func WriteDOT(w io.Writer, keyPrefix string, indexStructure *Index) (err error) {
+	d := dotWriter{w: w, indexStructure: indexStructure, shown: make(map[int]bool)}
+	keyPointer := indexStructure.indexRoot
+	if keyField := trimKey(keyPrefix); len(keyField) > 0 {
+		var found bool
+		if found, keyPointer, _ = locate(keyField, indexStructure); !found {
+			keyPointer = nullIndexPointer
+		}
+	}
+	//
+	d.printf("digraph index {\n")
+	d.printf("\tnode [shape=record, style=filled, fontname=monospace];\n")
+	if keyPointer != nullIndexPointer {
+		d.collect(keyPointer)
+		d.render(keyPointer, false)
+	}
+	d.printf("}\n")
+	err = d.err
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// WriteNodes writes the raw node array of the specified index to "w" as text, one node to a line, after a line
+// giving the root of the index and the root of the free list -- the runs of characters held by 'P' nodes follow
+//
+func WriteNodes(w io.Writer, indexStructure *Index) (err error) {
+	d := dotWriter{w: w, indexStructure: indexStructure}
+	d.printf("root %d free %d\n", indexStructure.indexRoot, indexStructure.deletedRoot)
+	for x := 0; x < indexStructure.nodeCount(); x++ {
+		node := indexStructure.nodeAt(x)
+		character := string(node.key)
+		if node.key < ' ' || node.key > '~' {
+			character = fmt.Sprintf("0x%02x", node.key)
+		}
+		d.printf("%8d %c %8d %-4s %8d\n", x, node.status, node.leftPointer, character, node.rightPointer)
+	}
+	for slot, run := range indexStructure.runs {
+		d.printf("run %8d %q\n", slot, run)
+	}
+	err = d.err
+	return
+}
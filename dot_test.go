@@ -0,0 +1,67 @@
+package key
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteNodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		insert []string
+		delete []string
+		want   string
+	}{
+		{name: "empty", want: "root -1 free -1\n"},
+		{name: "free list and runs", insert: []string{"abc", "ab", "ad"}, delete: []string{"ad"}, want: `root 2 free 3
+       0 S        0 c          -1
+       1 R        1 b           0
+       2 X       -1 a           1
+       3 D        1 b           4
+       4 S        2 d          -1
+run        0 ""
+`},
+		{name: "unprintable", insert: []string{"\x01\xff"}, want: `root 1 free -1
+       0 S        0 0xff       -1
+       1 X       -1 0x01        0
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var indexStructure Index
+			Initialise(&indexStructure)
+			for x, keyInput := range test.insert {
+				Insert(keyInput, x, &indexStructure)
+			}
+			for _, keyInput := range test.delete {
+				for x := range test.insert {
+					Delete(keyInput, x, &indexStructure)
+				}
+			}
+			if got := nodesText(t, &indexStructure); got != test.want {
+				t.Errorf("WriteNodes gave\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var indexStructure Index
+	Initialise(&indexStructure)
+	for x, keyInput := range []string{"abc", "ab", "ab", "b|"} {
+		Insert(keyInput, x, &indexStructure)
+	}
+	var b strings.Builder
+	if err := WriteDOT(&b, "", &indexStructure); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"digraph index {", `label="dup"`, `\|`, "style=dashed"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteDOT gave no %s in\n%s", want, b.String())
+		}
+	}
+	b.Reset()
+	if err := WriteDOT(&b, "zz", &indexStructure); err != nil || strings.Contains(b.String(), "->") {
+		t.Errorf("WriteDOT of a missing prefix gave %v\n%s", err, b.String())
+	}
+}
@@ -0,0 +1,69 @@
+package key
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// FuzzyMatch is one entry returned by SearchFuzzy
+//
+type FuzzyMatch struct {
+	Key       string
+	KeyNumber int
+	Distance  int // edits needed to turn the search key into this key
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchFuzzy returns every entry whose key is within "maxEdits" single character insertions, deletions or
+// substitutions of the input string, in ascending key order, along with the number of edits needed
+// the tree is walked one character at a time keeping a row of edit distances, and a branch is abandoned as soon as
+// every distance in the row is beyond "maxEdits"
+// "matchFound" is "true" if something is located
+//
+func SearchFuzzy(keyInput string, maxEdits int, indexStructure *Index) (matchFound bool, matches []FuzzyMatch) {
+	keyField := trimKey(keyInput)
+	if indexStructure.indexRoot == nullIndexPointer || maxEdits < 0 {
+		return
+	}
+	//
+	// rows[d][j] is the distance between the first d characters of the key so far and the first j of "keyField"
+	rows := make([][]int, 1, maxKeyLength+1)
+	rows[0] = make([]int, len(keyField)+1)
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	//
+	walk(indexStructure.indexRoot, nil, indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			depth := len(keyPath)
+			if len(rows) <= depth {
+				rows = append(rows, make([]int, len(keyField)+1))
+			}
+			above, row := rows[depth-1], rows[depth]
+			row[0] = above[0] + 1
+			best := row[0]
+			for j := 1; j <= len(keyField); j++ {
+				cost := 1
+				if keyField[j-1] == keyPath[depth-1] {
+					cost = 0
+				}
+				row[j] = min(above[j-1]+cost, above[j]+1, row[j-1]+1)
+				best = min(best, row[j])
+			}
+			descend = best <= maxEdits
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			distance := rows[len(keyPath)][len(keyField)]
+			if distance > maxEdits {
+				return
+			}
+			for _, keyNumber := range keyNumbers {
+				matches = append(matches, FuzzyMatch{Key: string(keyPath), KeyNumber: keyNumber, Distance: distance})
+			}
+		})
+	matchFound = len(matches) > 0
+	return
+}
@@ -0,0 +1,71 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func levenshtein(a, b string) int {
+	// the edit distance between two strings of bytes, worked out in full
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			diagonal, row[j] = row[j], min(diagonal+cost, row[j]+1, row[j-1]+1)
+		}
+	}
+	return row[len(b)]
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestSearchFuzzy(t *testing.T) {
+	var indexStructure Index
+	Initialise(&indexStructure)
+	keys := []string{"kitten", "sitting", "mitten", "kit", "bitten", "smitten", "kitten", strings.Repeat("x", 40),
+		strings.Repeat("x", 30) + "yy", "é", "e", "a"}
+	r := rand.New(rand.NewSource(1))
+	for x := 0; x < 300; x++ {
+		keys = append(keys, randomKey(r, "abc\xc3\xa9", 6))
+	}
+	m := testModel{}
+	for x, keyInput := range keys {
+		Insert(keyInput, x, &indexStructure)
+		m.insert(keyInput, x)
+	}
+	//
+	searches := []string{"kitten", "kitn", "sitten", "", "a", "ab", "cab", "abcabc", "é", "\xc3", "zzzz",
+		strings.Repeat("x", 33), strings.Repeat("x", 31)}
+	for _, keyInput := range searches {
+		for maxEdits := -1; maxEdits <= 3; maxEdits++ {
+			var want []FuzzyMatch
+			for _, entry := range m.entries() {
+				if distance := levenshtein(trimKey(keyInput), entry.key); distance <= maxEdits {
+					want = append(want, FuzzyMatch{Key: entry.key, KeyNumber: entry.keyNumber, Distance: distance})
+				}
+			}
+			matchFound, got := SearchFuzzy(keyInput, maxEdits, &indexStructure)
+			if matchFound != (len(want) > 0) || fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("SearchFuzzy(%q, %d) gave %v %v, want %v", keyInput, maxEdits, matchFound, got, want)
+			}
+		}
+	}
+	//
+	var empty Index
+	Initialise(&empty)
+	if matchFound, got := SearchFuzzy("kitten", 2, &empty); matchFound || got != nil {
+		t.Errorf("SearchFuzzy on an empty index gave %v %v", matchFound, got)
+	}
+}
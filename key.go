@@ -1,6 +1,7 @@
 package key
 
 import (
+	"math"
 	"strconv"
 	"strings"
 )
@@ -8,8 +9,13 @@ import (
 const (
 	maxKeyLength     = 32
 	nullIndexPointer = -1
+	runHeaderSize    = 2 * strconv.IntSize / 8 // bytes of the string header each run of a 'P' node is held in
 )
 
+// MaxKeyLength is the longest key an index keeps -- longer keys are cut short by Insert and every search
+//
+const MaxKeyLength = maxKeyLength
+
 //
 /////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
 //
@@ -26,6 +32,17 @@ type Statistic struct {
 	NodeK   int
 	NodeL   int
 	NodeD   int
+	NodeP   int // runs of characters held in one node
+	//
+	Keys             int       // distinct keys
+	Entries          int       // key and "index-number" pairs, counting every duplicate
+	LargestDuplicate int       // most "index-numbers" filed under a single key
+	AverageKeyLength float64   // over the distinct keys
+	MaximumKeyLength int       // longest distinct key
+	DecisionChain    []float64 // average number of 'D' nodes passed to reach a character, by character position
+	BytesInUse       int       // memory held by the active nodes and their runs of characters
+	BytesFree        int       // memory held by the nodes on the free list
+	DepthHistogram   []int     // distinct keys by the number of nodes from the root to the end of the key
 }
 
 type indexNode struct {
@@ -44,7 +61,15 @@ type indexNode struct {
 type Index struct {
 	indexRoot   int
 	node        []indexNode
+	packed      []packedNode // used instead of "node" by the compact layout
+	compact     bool
 	deletedRoot int
+	runs        []string // characters of the 'P' nodes
+	freeRuns    []int
+	freeNodes   *freeMap // only kept for allocations other than the default
+	observers   []*observer
+	suffixes    *suffixIndex
+	weights     *weightIndex
 }
 
 //
@@ -88,10 +113,11 @@ func decimaliseNumber(keyNumber int) (keyField string, keyLength int) {
 /////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
 //
 
-func extend(keyField string, keyNumber, nextBranchBasePointer int, indexStructure *Index) (extensionPointer int) {
+func extend(keyField string, keyNumber, nextBranchBasePointer, parentPointer int,
+	indexStructure *Index) (extensionPointer int) {
 	// creates key nodes in reverse order -- points the leaf node at the next branch sent in as a parameter
+	// "parentPointer" is the node that will point to the first of them, or null
 	var new indexNode
-	var newIndexNumber int
 	//
 	keyLength := len(keyField)
 	extensionPointer = nextBranchBasePointer
@@ -109,17 +135,7 @@ func extend(keyField string, keyNumber, nextBranchBasePointer int, indexStructur
 			new.leftPointer = nullIndexPointer
 		}
 		//
-		if indexStructure.deletedRoot == nullIndexPointer {
-			// append to end of array
-			indexStructure.node = append(indexStructure.node, new)
-			newIndexNumber = len(indexStructure.node) - 1
-		} else {
-			// use up one of the "deleted" nodes
-			newIndexNumber = indexStructure.deletedRoot
-			indexStructure.deletedRoot = indexStructure.node[newIndexNumber].rightPointer
-			indexStructure.node[newIndexNumber] = new
-		}
-		extensionPointer = newIndexNumber
+		extensionPointer = indexStructure.allocate(new, parentPointer)
 	}
 	//
 	return
@@ -170,24 +186,24 @@ func Search(keyInput string, searchPrecisely bool, indexStructure *Index) (match
 			searching = false
 			break
 		}
-		switch indexStructure.node[keyPointer].status {
+		switch indexStructure.nodeAt(keyPointer).status {
 		//
 		case 'R', 'S':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength { // last character in key
 					if searchPrecisely { //  narrow the range to just this node
-						lastMatchPointer = indexStructure.node[keyPointer].rightPointer
+						lastMatchPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					}
 					matchFound = true
 					searching = false
 					break
 				} else { // more characters remain in key
-					if indexStructure.node[keyPointer].status == 'S' { // at the terminal leaf so it doesn't match
+					if indexStructure.nodeAt(keyPointer).status == 'S' { // at the terminal leaf so it doesn't match
 						matchFound = false
 						searching = false
 						break
 					} // keep traversing
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else { // key doesn't match
@@ -197,32 +213,32 @@ func Search(keyInput string, searchPrecisely bool, indexStructure *Index) (match
 			}
 		//
 		case 'D':
-			if keyField[i:i+1] <= string(indexStructure.node[keyPointer].key) {
+			if keyField[i] <= indexStructure.nodeAt(keyPointer).key {
 				if !searchPrecisely { // global search
 					lastMatchPointer = keyPointer //  keep track of the base of the "current" branch
 				}
-				keyPointer = indexStructure.node[keyPointer].leftPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 			} else {
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 			}
 		//
 		case 'K', 'L':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength { // last character in key
 					if searchPrecisely { //  narrow the range
 						lastMatchPointer = keyPointer
 					}
-					keyPointer = indexStructure.node[keyPointer].leftPointer //  move into the duplicate branch
+					keyPointer = indexStructure.nodeAt(keyPointer).leftPointer //  move into the duplicate branch
 					matchFound = true
 					searching = false
 					break
 				} else { // more characters remain in key
-					if indexStructure.node[keyPointer].status == 'L' { // at the terminal leaf so it doesn't match
+					if indexStructure.nodeAt(keyPointer).status == 'L' { // at the terminal leaf so it doesn't match
 						matchFound = false
 						searching = false
 						break
 					} // keep traversing
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else { // key doesn't match
@@ -232,18 +248,18 @@ func Search(keyInput string, searchPrecisely bool, indexStructure *Index) (match
 			}
 		//
 		case 'X':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength { // last character in key
 					if searchPrecisely {
 						matchFound = false
 					} else { // global search
-						keyPointer = indexStructure.node[keyPointer].rightPointer
+						keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 						matchFound = true
 					}
 					searching = false
 					break
 				} else { // more characters remain in key so keep traversing
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else { // key doesn't match
@@ -251,6 +267,29 @@ func Search(keyInput string, searchPrecisely bool, indexStructure *Index) (match
 				searching = false
 				break
 			}
+		//
+		case 'P':
+			run := indexStructure.runs[indexStructure.nodeAt(keyPointer).leftPointer]
+			length := min(len(run), keyLength-i)
+			if keyField[i:i+length] == run[:length] {
+				if i+length == keyLength { // key ends inside the run
+					if searchPrecisely {
+						matchFound = false
+					} else { // global search
+						keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
+						matchFound = true
+					}
+					searching = false
+					break
+				} else { // more characters remain in key so keep traversing
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
+					i += length
+				}
+			} else { // key doesn't match
+				matchFound = false
+				searching = false
+				break
+			}
 		}
 	} // end searching
 	//
@@ -258,43 +297,43 @@ func Search(keyInput string, searchPrecisely bool, indexStructure *Index) (match
 		// scan the tree and collect results -- from keyPointer to (lastMatchPointer or -1)
 		goLeftAtNextNode := true
 		for keyPointer != lastMatchPointer && keyPointer != nullIndexPointer {
-			switch indexStructure.node[keyPointer].status {
+			switch indexStructure.nodeAt(keyPointer).status {
 			//
 			case 'R':
-				indexes = append(indexes, indexStructure.node[keyPointer].leftPointer)
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				indexes = append(indexes, indexStructure.nodeAt(keyPointer).leftPointer)
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				goLeftAtNextNode = true
 			//
 			case 'S':
-				indexes = append(indexes, indexStructure.node[keyPointer].leftPointer)
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				indexes = append(indexes, indexStructure.nodeAt(keyPointer).leftPointer)
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				goLeftAtNextNode = false
 			//
 			case 'K':
 				if goLeftAtNextNode {
-					keyPointer = indexStructure.node[keyPointer].leftPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 				} else {
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				}
 				goLeftAtNextNode = true
 			//
 			case 'L':
 				if goLeftAtNextNode {
-					keyPointer = indexStructure.node[keyPointer].leftPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 				} else {
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				}
 			//
 			case 'D':
 				if goLeftAtNextNode {
-					keyPointer = indexStructure.node[keyPointer].leftPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 				} else {
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				}
 				goLeftAtNextNode = true
 			//
-			case 'X':
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+			case 'X', 'P':
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				goLeftAtNextNode = true
 			}
 		}
@@ -306,10 +345,55 @@ func Search(keyInput string, searchPrecisely bool, indexStructure *Index) (match
 /////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
 //
 
+func release(keyPointer int, indexStructure *Index) {
+	// puts every node of a duplicate branch on the free list
+	node := indexStructure.nodeAt(keyPointer)
+	if node.status == 'D' {
+		release(node.leftPointer, indexStructure)
+	}
+	if node.status == 'D' || node.status == 'X' || node.status == 'R' {
+		release(node.rightPointer, indexStructure)
+	}
+	indexStructure.free(keyPointer)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
 // Delete removes a key and its associated "index-number" from the supplied index
 //
 func Delete(keyInput string, keyNumber int, indexStructure *Index) {
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 {
+		return
+	}
+	if len(indexStructure.observers) > 0 {
+		if _, present := indexStructure.entryState(keyField, keyNumber); !present {
+			return
+		}
+	}
+	if indexStructure.suffixes != nil {
+		indexStructure.suffixes.delete(keyField, keyNumber)
+	}
+	indexStructure.openPath(keyField)
+	deleteKey(keyField, keyNumber, rootDescent, indexStructure)
+	indexStructure.closePath(keyField, rootDescent)
+	if indexStructure.weights != nil {
+		indexStructure.weights.forget(keyField, keyNumber)
+		indexStructure.weights.refresh(keyField, indexStructure)
+	}
+	if len(indexStructure.observers) > 0 {
+		indexStructure.notifyDelete(keyField, keyNumber)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
 
+func deleteKey(keyInput string, keyNumber int, from descent, indexStructure *Index) {
+	// "from" is the level the search starts at, which is the root of the index for everything but ApplyBatch
 	var keyField string
 	//
 	keyInput = strings.TrimSpace(keyInput)
@@ -325,43 +409,43 @@ func Delete(keyInput string, keyNumber int, indexStructure *Index) {
 	if indexStructure.indexRoot == nullIndexPointer { // no index
 		return
 	}
-	keyPointer := indexStructure.indexRoot
-	previousIndexNumber := nullIndexPointer
-	linkIndexNumber := nullIndexPointer
-	deleteIndexNumber := nullIndexPointer
+	keyPointer := indexStructure.levelRoot(from.levelPointer)
+	previousIndexNumber := from.levelPointer
+	linkIndexNumber := from.linkIndexNumber
+	deleteIndexNumber := from.deleteIndexNumber
 	duplicateIndexNumber := nullIndexPointer
 	//
-	goLeft := true
+	goLeft := from.goLeft
 	duplicateCount := 0
-	i := 0
+	i := from.i
 	for searching := true; searching; { // start searching
 		//
-		if indexStructure.node[keyPointer].status == 'D' ||
-			indexStructure.node[keyPointer].status == 'K' ||
-			indexStructure.node[keyPointer].status == 'R' {
+		if indexStructure.nodeAt(keyPointer).status == 'D' ||
+			indexStructure.nodeAt(keyPointer).status == 'K' ||
+			indexStructure.nodeAt(keyPointer).status == 'R' {
 			deleteIndexNumber = keyPointer
 			linkIndexNumber = previousIndexNumber
 		}
 		//
-		switch indexStructure.node[keyPointer].status {
+		switch indexStructure.nodeAt(keyPointer).status {
 		//
 		case 'R', 'S':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
-				if duplicateIndexNumber != nullIndexPointer && indexStructure.node[keyPointer].status == 'R' {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
+				if duplicateIndexNumber != nullIndexPointer && indexStructure.nodeAt(keyPointer).status == 'R' {
 					duplicateCount++
 				}
 				if i+1 == keyLength {
-					if indexStructure.node[keyPointer].leftPointer != keyNumber {
+					if indexStructure.nodeAt(keyPointer).leftPointer != keyNumber {
 						return
 					}
 					searching = false
 					break
 				} else {
-					if indexStructure.node[keyPointer].status == 'S' {
+					if indexStructure.nodeAt(keyPointer).status == 'S' {
 						return
 					}
 					previousIndexNumber = keyPointer
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else {
@@ -373,40 +457,40 @@ func Delete(keyInput string, keyNumber int, indexStructure *Index) {
 				duplicateCount++
 			}
 			previousIndexNumber = keyPointer
-			if keyField[i:i+1] <= string(indexStructure.node[keyPointer].key) {
-				keyPointer = indexStructure.node[keyPointer].leftPointer
+			if keyField[i] <= indexStructure.nodeAt(keyPointer).key {
+				keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 				goLeft = true
 			} else {
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				goLeft = false
 			}
 			//
 		case 'K', 'L':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				previousIndexNumber = keyPointer
 				if i+1 == keyLength {
 					duplicateIndexNumber = keyPointer
 					i = 0
 					keyField, keyLength = decimaliseNumber(keyNumber)
-					keyPointer = indexStructure.node[keyPointer].leftPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 				} else {
-					if indexStructure.node[keyPointer].status == 'L' {
+					if indexStructure.nodeAt(keyPointer).status == 'L' {
 						return
 					}
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else {
 				return
 			}
 			//
-		case 'X':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+		case 'X', 'P': // a run is only met when its first character does not match
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength {
 					return
 				}
 				previousIndexNumber = keyPointer
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				i++
 			} else {
 				return
@@ -417,182 +501,121 @@ func Delete(keyInput string, keyNumber int, indexStructure *Index) {
 	if duplicateIndexNumber != nullIndexPointer { // duplicate tree found
 		//
 		if duplicateCount == 0 { // should never happen
-			indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-			indexStructure.deletedRoot = indexStructure.node[duplicateIndexNumber].leftPointer
-			if indexStructure.node[duplicateIndexNumber].status == 'K' {
-				indexStructure.node[duplicateIndexNumber].status = 'X'
-				indexStructure.node[duplicateIndexNumber].leftPointer = nullIndexPointer
+			indexStructure.freeChain(indexStructure.nodeAt(duplicateIndexNumber).leftPointer, keyPointer)
+			if indexStructure.nodeAt(duplicateIndexNumber).status == 'K' {
+				indexStructure.setStatus(duplicateIndexNumber, 'X')
+				indexStructure.setLeftPointer(duplicateIndexNumber, nullIndexPointer)
 			} else {
-				indexStructure.node[duplicateIndexNumber].status = 'S'
-				indexStructure.node[duplicateIndexNumber].leftPointer = keyNumber
+				indexStructure.setStatus(duplicateIndexNumber, 'S')
+				indexStructure.setLeftPointer(duplicateIndexNumber, keyNumber)
 			}
-			if indexStructure.node[duplicateIndexNumber].status == 'X' {
+			if indexStructure.nodeAt(duplicateIndexNumber).status == 'X' {
 				return
 			}
 			keyPointer = duplicateIndexNumber
 			duplicateIndexNumber = nullIndexPointer
 			//
-		} else {
-			var tempIndexNumber int
-			if duplicateCount == 1 { // scan up the tree looking for branches ('D') and keys ('R')
-				if indexStructure.node[keyPointer].status == 'R' {
-					tempIndexNumber = indexStructure.node[keyPointer].rightPointer
-				} else if indexStructure.node[deleteIndexNumber].status == 'R' || goLeft {
-					tempIndexNumber = indexStructure.node[deleteIndexNumber].rightPointer
-				} else {
-					tempIndexNumber = indexStructure.node[deleteIndexNumber].leftPointer
-				}
-				for searching := true; searching; {
-					if indexStructure.node[tempIndexNumber].status == 'R' ||
-						(indexStructure.node[tempIndexNumber].status == 'D' &&
-							tempIndexNumber != deleteIndexNumber) {
-						duplicateCount++
-					}
-					if indexStructure.node[tempIndexNumber].rightPointer == duplicateIndexNumber ||
-						duplicateCount > 1 {
-						searching = false
-					} else {
-						tempIndexNumber = indexStructure.node[tempIndexNumber].rightPointer
-					}
+		} else if duplicateCount == 1 &&
+			countDuplicates(indexStructure.nodeAt(duplicateIndexNumber).leftPointer, 3, indexStructure) == 2 {
+			// only one "index-number" will be left so the whole duplicate branch goes -- every branch and key passed on
+			// the way down holds another "index-number", so the count is only needed when just one was passed
+			duplicateRoot := indexStructure.nodeAt(duplicateIndexNumber).leftPointer
+			remainingNumber := keyNumber
+			for _, x := range duplicateNumbers(duplicateRoot, indexStructure, nil) {
+				if x != keyNumber {
+					remainingNumber = x
 				}
 			}
-			if duplicateCount == 1 { // still only got one duplicate after scanning up the tree
-				if indexStructure.node[keyPointer].status == 'R' {
-					indexStructure.node[tempIndexNumber].rightPointer = indexStructure.deletedRoot
-					indexStructure.deletedRoot = indexStructure.node[duplicateIndexNumber].leftPointer
-					if indexStructure.node[duplicateIndexNumber].status == 'K' {
-						indexStructure.node[duplicateIndexNumber].status = 'R'
-					} else {
-						indexStructure.node[duplicateIndexNumber].status = 'S'
-					}
-					indexStructure.node[duplicateIndexNumber].leftPointer =
-						indexStructure.node[tempIndexNumber].leftPointer
-					return
-				}
-				if indexStructure.node[linkIndexNumber].status == 'K' {
-					indexStructure.node[linkIndexNumber].status = 'R'
-				} else {
-					indexStructure.node[linkIndexNumber].status = 'S'
-				}
-				if indexStructure.node[deleteIndexNumber].status == 'R' {
-					indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-					indexStructure.deletedRoot = indexStructure.node[linkIndexNumber].leftPointer
-					indexStructure.node[linkIndexNumber].leftPointer =
-						indexStructure.node[deleteIndexNumber].leftPointer
-
-				} else {
-					saveIndex := indexStructure.node[linkIndexNumber].leftPointer
-					var resetRightIndex, endIndex int
-					if goLeft {
-						resetRightIndex = indexStructure.node[deleteIndexNumber].rightPointer
-						endIndex = duplicateIndexNumber
-					} else {
-						resetRightIndex = indexStructure.node[deleteIndexNumber].leftPointer
-						endIndex = deleteIndexNumber
-					}
-					for indexStructure.node[resetRightIndex].rightPointer != endIndex {
-						resetRightIndex = indexStructure.node[resetRightIndex].rightPointer
-					}
-					indexStructure.node[linkIndexNumber].leftPointer =
-						indexStructure.node[resetRightIndex].leftPointer
-					if goLeft {
-						indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-						indexStructure.node[resetRightIndex].rightPointer =
-							indexStructure.node[deleteIndexNumber].leftPointer
-					} else {
-						indexStructure.node[resetRightIndex].rightPointer = indexStructure.deletedRoot
-						indexStructure.node[keyPointer].rightPointer =
-							indexStructure.node[deleteIndexNumber].leftPointer
-					}
-					indexStructure.deletedRoot = saveIndex
-				}
-				return
+			release(duplicateRoot, indexStructure)
+			if indexStructure.nodeAt(duplicateIndexNumber).status == 'K' {
+				indexStructure.setStatus(duplicateIndexNumber, 'R')
+			} else {
+				indexStructure.setStatus(duplicateIndexNumber, 'S')
 			}
+			indexStructure.setLeftPointer(duplicateIndexNumber, remainingNumber)
+			return
 		}
 	} // end duplicate tree
 	//
-	if indexStructure.node[keyPointer].status == 'R' {
-		indexStructure.node[keyPointer].status = 'X'
-		indexStructure.node[keyPointer].leftPointer = nullIndexPointer
+	if indexStructure.nodeAt(keyPointer).status == 'R' {
+		indexStructure.setStatus(keyPointer, 'X')
+		indexStructure.setLeftPointer(keyPointer, nullIndexPointer)
 		return
 	}
 	//
 	if deleteIndexNumber == nullIndexPointer {
-		indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-		indexStructure.deletedRoot = indexStructure.indexRoot
+		indexStructure.freeChain(indexStructure.indexRoot, keyPointer)
 		indexStructure.indexRoot = nullIndexPointer
 		return
 	}
 	//
-	if indexStructure.node[deleteIndexNumber].status == 'R' || indexStructure.node[deleteIndexNumber].status == 'K' {
-		saveIndex := indexStructure.node[deleteIndexNumber].rightPointer
-		indexStructure.node[deleteIndexNumber].rightPointer = indexStructure.node[keyPointer].rightPointer
-		if indexStructure.node[deleteIndexNumber].status == 'R' {
-			indexStructure.node[deleteIndexNumber].status = 'S'
+	if indexStructure.nodeAt(deleteIndexNumber).status == 'R' ||
+		indexStructure.nodeAt(deleteIndexNumber).status == 'K' {
+		saveIndex := indexStructure.nodeAt(deleteIndexNumber).rightPointer
+		indexStructure.setRightPointer(deleteIndexNumber, indexStructure.nodeAt(keyPointer).rightPointer)
+		if indexStructure.nodeAt(deleteIndexNumber).status == 'R' {
+			indexStructure.setStatus(deleteIndexNumber, 'S')
 		} else {
-			indexStructure.node[deleteIndexNumber].status = 'L'
+			indexStructure.setStatus(deleteIndexNumber, 'L')
 		}
-		indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-		indexStructure.deletedRoot = saveIndex
+		indexStructure.freeChain(saveIndex, keyPointer)
 		return
 	}
 	//
 	if goLeft {
 		if linkIndexNumber == nullIndexPointer {
-			indexStructure.indexRoot = indexStructure.node[deleteIndexNumber].rightPointer
+			indexStructure.indexRoot = indexStructure.nodeAt(deleteIndexNumber).rightPointer
 		} else {
-			if indexStructure.node[linkIndexNumber].status == 'D' {
-				if indexStructure.node[deleteIndexNumber].key <= indexStructure.node[linkIndexNumber].key {
-					resetIndex := indexStructure.node[deleteIndexNumber].rightPointer
-					if indexStructure.node[resetIndex].status != 'D' {
-						indexStructure.node[linkIndexNumber].key = indexStructure.node[resetIndex].key
+			if indexStructure.nodeAt(linkIndexNumber).status == 'D' {
+				if indexStructure.nodeAt(deleteIndexNumber).key <= indexStructure.nodeAt(linkIndexNumber).key {
+					resetIndex := indexStructure.nodeAt(deleteIndexNumber).rightPointer
+					if indexStructure.nodeAt(resetIndex).status != 'D' {
+						indexStructure.setKey(linkIndexNumber, indexStructure.nodeAt(resetIndex).key)
 					}
-					indexStructure.node[linkIndexNumber].leftPointer =
-						indexStructure.node[deleteIndexNumber].rightPointer
+					indexStructure.setLeftPointer(linkIndexNumber,
+						indexStructure.nodeAt(deleteIndexNumber).rightPointer)
 				} else {
-					indexStructure.node[linkIndexNumber].rightPointer =
-						indexStructure.node[deleteIndexNumber].rightPointer
+					indexStructure.setRightPointer(linkIndexNumber,
+						indexStructure.nodeAt(deleteIndexNumber).rightPointer)
 				}
-			} else if (indexStructure.node[linkIndexNumber].status == 'K' ||
-				indexStructure.node[linkIndexNumber].status == 'L') && duplicateIndexNumber != nullIndexPointer {
-				indexStructure.node[linkIndexNumber].leftPointer = indexStructure.node[deleteIndexNumber].rightPointer
+			} else if (indexStructure.nodeAt(linkIndexNumber).status == 'K' ||
+				indexStructure.nodeAt(linkIndexNumber).status == 'L') && duplicateIndexNumber != nullIndexPointer {
+				indexStructure.setLeftPointer(linkIndexNumber, indexStructure.nodeAt(deleteIndexNumber).rightPointer)
 			} else {
-				indexStructure.node[linkIndexNumber].rightPointer = indexStructure.node[deleteIndexNumber].rightPointer
+				indexStructure.setRightPointer(linkIndexNumber, indexStructure.nodeAt(deleteIndexNumber).rightPointer)
 			}
 		}
-		indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-		indexStructure.deletedRoot = deleteIndexNumber
-		indexStructure.node[deleteIndexNumber].rightPointer = indexStructure.node[deleteIndexNumber].leftPointer
+		indexStructure.setRightPointer(deleteIndexNumber, indexStructure.nodeAt(deleteIndexNumber).leftPointer)
+		indexStructure.freeChain(deleteIndexNumber, keyPointer)
 	} else {
-		threadIndex := indexStructure.node[deleteIndexNumber].leftPointer
-		for indexStructure.node[threadIndex].rightPointer != deleteIndexNumber {
-			threadIndex = indexStructure.node[threadIndex].rightPointer
+		threadIndex := indexStructure.nodeAt(deleteIndexNumber).leftPointer
+		for indexStructure.nodeAt(threadIndex).rightPointer != deleteIndexNumber {
+			threadIndex = indexStructure.nodeAt(threadIndex).rightPointer
 		}
-		indexStructure.node[threadIndex].rightPointer = indexStructure.node[keyPointer].rightPointer
+		indexStructure.setRightPointer(threadIndex, indexStructure.nodeAt(keyPointer).rightPointer)
 		if linkIndexNumber == nullIndexPointer {
-			indexStructure.indexRoot = indexStructure.node[deleteIndexNumber].leftPointer
+			indexStructure.indexRoot = indexStructure.nodeAt(deleteIndexNumber).leftPointer
 		} else {
-			if indexStructure.node[linkIndexNumber].status == 'D' {
-				if indexStructure.node[deleteIndexNumber].key <= indexStructure.node[linkIndexNumber].key {
-					resetIndex := indexStructure.node[deleteIndexNumber].leftPointer
-					if indexStructure.node[resetIndex].status != 'D' {
-						indexStructure.node[linkIndexNumber].key = indexStructure.node[resetIndex].key
+			if indexStructure.nodeAt(linkIndexNumber).status == 'D' {
+				if indexStructure.nodeAt(deleteIndexNumber).key <= indexStructure.nodeAt(linkIndexNumber).key {
+					resetIndex := indexStructure.nodeAt(deleteIndexNumber).leftPointer
+					if indexStructure.nodeAt(resetIndex).status != 'D' {
+						indexStructure.setKey(linkIndexNumber, indexStructure.nodeAt(resetIndex).key)
 					}
-					indexStructure.node[linkIndexNumber].leftPointer =
-						indexStructure.node[deleteIndexNumber].leftPointer
+					indexStructure.setLeftPointer(linkIndexNumber,
+						indexStructure.nodeAt(deleteIndexNumber).leftPointer)
 				} else {
-					indexStructure.node[linkIndexNumber].rightPointer =
-						indexStructure.node[deleteIndexNumber].leftPointer
+					indexStructure.setRightPointer(linkIndexNumber,
+						indexStructure.nodeAt(deleteIndexNumber).leftPointer)
 				}
-			} else if (indexStructure.node[linkIndexNumber].status == 'K' ||
-				indexStructure.node[linkIndexNumber].status == 'L') && duplicateIndexNumber != nullIndexPointer {
-				indexStructure.node[linkIndexNumber].leftPointer = indexStructure.node[deleteIndexNumber].leftPointer
+			} else if (indexStructure.nodeAt(linkIndexNumber).status == 'K' ||
+				indexStructure.nodeAt(linkIndexNumber).status == 'L') && duplicateIndexNumber != nullIndexPointer {
+				indexStructure.setLeftPointer(linkIndexNumber, indexStructure.nodeAt(deleteIndexNumber).leftPointer)
 			} else {
-				indexStructure.node[linkIndexNumber].rightPointer = indexStructure.node[deleteIndexNumber].leftPointer
+				indexStructure.setRightPointer(linkIndexNumber, indexStructure.nodeAt(deleteIndexNumber).leftPointer)
 			}
 		}
-		indexStructure.node[keyPointer].rightPointer = indexStructure.deletedRoot
-		indexStructure.deletedRoot = deleteIndexNumber
+		indexStructure.freeChain(deleteIndexNumber, keyPointer)
 	}
 	//
 }
@@ -602,8 +625,45 @@ func Delete(keyInput string, keyNumber int, indexStructure *Index) {
 //
 
 // Insert places the input string into the specified index structure along with the supplied "index-number"
+// a blank key, or an "index-number" that does not fit the compact layout, is ignored -- use ApplyBatch or a
+// Transaction to be told
 //
 func Insert(keyInput string, keyNumber int, indexStructure *Index) {
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 || !indexStructure.holdsNumber(keyNumber) {
+		return
+	}
+	event := Event{Kind: KeyInserted, Key: keyField, KeyNumber: keyNumber}
+	if len(indexStructure.observers) > 0 {
+		keyFound, present := indexStructure.entryState(keyField, keyNumber)
+		if present {
+			return
+		}
+		if keyFound {
+			event.Kind = DuplicateAdded
+		}
+	}
+	if indexStructure.suffixes != nil {
+		indexStructure.suffixes.insert(keyField, keyNumber)
+	}
+	indexStructure.openPath(keyField)
+	insertKey(keyField, keyNumber, rootDescent, indexStructure)
+	indexStructure.closePath(keyField, rootDescent)
+	if indexStructure.weights != nil {
+		indexStructure.weights.refresh(keyField, indexStructure)
+	}
+	indexStructure.balancePath(keyField, rootDescent)
+	if len(indexStructure.observers) > 0 {
+		indexStructure.notify(event)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func insertKey(keyInput string, keyNumber int, from descent, indexStructure *Index) {
+	// "from" is the level the search starts at, which is the root of the index for everything but ApplyBatch
 	var keyField string
 	var decisionIndexNumber, lastIndexNumber int
 	//
@@ -618,44 +678,45 @@ func Insert(keyInput string, keyNumber int, indexStructure *Index) {
 		return
 	}
 	if indexStructure.indexRoot == nullIndexPointer { // no index so just put the key straight into the structure
-		indexStructure.indexRoot = extend(keyField, keyNumber, nullIndexPointer, indexStructure)
+		indexStructure.indexRoot = extend(keyField, keyNumber, nullIndexPointer, nullIndexPointer, indexStructure)
 		return
 	}
-	keyPointer := indexStructure.indexRoot
-	previousIndexNumber := nullIndexPointer
+	keyPointer := indexStructure.levelRoot(from.levelPointer)
+	previousIndexNumber := from.levelPointer
 	duplicateFlag := false
-	i := 0
+	i := from.i
 	//
 	for searching := true; searching; { // start searching
-		switch indexStructure.node[keyPointer].status {
+		switch indexStructure.nodeAt(keyPointer).status {
 		//
 		case 'R', 'S':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength {
-					if keyNumber == indexStructure.node[keyPointer].leftPointer { // new key is EXACTLY same as existing
+					// new key is EXACTLY same as existing
+					if keyNumber == indexStructure.nodeAt(keyPointer).leftPointer {
 						return // key value and key number are the same so do nothing
 					}
-					keyField, keyLength = decimaliseNumber(indexStructure.node[keyPointer].leftPointer)
-					linkIndexNumber :=
-						extend(keyField, indexStructure.node[keyPointer].leftPointer, keyPointer, indexStructure)
-					if indexStructure.node[keyPointer].status == 'R' {
-						indexStructure.node[keyPointer].status = 'K'
+					keyField, keyLength = decimaliseNumber(indexStructure.nodeAt(keyPointer).leftPointer)
+					linkIndexNumber := extend(keyField, indexStructure.nodeAt(keyPointer).leftPointer, keyPointer,
+						keyPointer, indexStructure)
+					if indexStructure.nodeAt(keyPointer).status == 'R' {
+						indexStructure.setStatus(keyPointer, 'K')
 					} else {
-						indexStructure.node[keyPointer].status = 'L'
+						indexStructure.setStatus(keyPointer, 'L')
 					}
-					indexStructure.node[keyPointer].leftPointer = linkIndexNumber
+					indexStructure.setLeftPointer(keyPointer, linkIndexNumber)
 					duplicateFlag = true
 					i = 0
 					keyField, keyLength = decimaliseNumber(keyNumber)
 					previousIndexNumber = keyPointer
 					keyPointer = linkIndexNumber
 				} else {
-					if indexStructure.node[keyPointer].status == 'S' {
+					if indexStructure.nodeAt(keyPointer).status == 'S' {
 						searching = false
 						break
 					}
 					previousIndexNumber = keyPointer
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else {
@@ -665,27 +726,27 @@ func Insert(keyInput string, keyNumber int, indexStructure *Index) {
 		//
 		case 'D':
 			previousIndexNumber = keyPointer
-			if keyField[i:i+1] <= string(indexStructure.node[keyPointer].key) {
-				keyPointer = indexStructure.node[keyPointer].leftPointer
+			if keyField[i] <= indexStructure.nodeAt(keyPointer).key {
+				keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 			} else {
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 			}
 		//
 		case 'K', 'L':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength {
 					duplicateFlag = true
 					keyField, keyLength = decimaliseNumber(keyNumber) // start a new key
 					i = 0
 					previousIndexNumber = keyPointer
-					keyPointer = indexStructure.node[keyPointer].leftPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 				} else {
-					if indexStructure.node[keyPointer].status == 'L' {
+					if indexStructure.nodeAt(keyPointer).status == 'L' {
 						searching = false
 						break
 					}
 					previousIndexNumber = keyPointer
-					keyPointer = indexStructure.node[keyPointer].rightPointer
+					keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 					i++
 				}
 			} else {
@@ -693,14 +754,14 @@ func Insert(keyInput string, keyNumber int, indexStructure *Index) {
 				break
 			}
 		//
-		case 'X':
-			if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
+		case 'X', 'P': // a run is only met when its first character does not match
+			if keyField[i] == indexStructure.nodeAt(keyPointer).key {
 				if i+1 == keyLength {
 					searching = false
 					break
 				}
 				previousIndexNumber = keyPointer
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				i++
 			} else {
 				searching = false
@@ -709,21 +770,21 @@ func Insert(keyInput string, keyNumber int, indexStructure *Index) {
 		}
 	} // end searching
 	//
-	if keyField[i:i+1] == string(indexStructure.node[keyPointer].key) {
-		if indexStructure.node[keyPointer].status == 'X' {
-			indexStructure.node[keyPointer].status = 'R'
-			indexStructure.node[keyPointer].leftPointer = keyNumber
+	if keyField[i] == indexStructure.nodeAt(keyPointer).key {
+		if indexStructure.nodeAt(keyPointer).status == 'X' {
+			indexStructure.setStatus(keyPointer, 'R')
+			indexStructure.setLeftPointer(keyPointer, keyNumber)
 			return
 		}
 		i++
-		linkIndexNumber :=
-			extend(keyField[i:], keyNumber, indexStructure.node[keyPointer].rightPointer, indexStructure)
-		if indexStructure.node[keyPointer].status == 'S' {
-			indexStructure.node[keyPointer].status = 'R'
+		linkIndexNumber := extend(keyField[i:], keyNumber, indexStructure.nodeAt(keyPointer).rightPointer, keyPointer,
+			indexStructure)
+		if indexStructure.nodeAt(keyPointer).status == 'S' {
+			indexStructure.setStatus(keyPointer, 'R')
 		} else {
-			indexStructure.node[keyPointer].status = 'K' // was an "L" before
+			indexStructure.setStatus(keyPointer, 'K') // was an "L" before
 		}
-		indexStructure.node[keyPointer].rightPointer = linkIndexNumber
+		indexStructure.setRightPointer(keyPointer, linkIndexNumber)
 		return
 	}
 	//
@@ -733,51 +794,46 @@ func Insert(keyInput string, keyNumber int, indexStructure *Index) {
 		key:          ' ',
 		rightPointer: nullIndexPointer,
 	}
-	if indexStructure.deletedRoot == nullIndexPointer {
-		// append new decision to end of array
-		indexStructure.node = append(indexStructure.node, placeholderNode)
-		decisionIndexNumber = len(indexStructure.node) - 1
+	if previousIndexNumber == nullIndexPointer {
+		decisionIndexNumber = indexStructure.allocate(placeholderNode, keyPointer)
 	} else {
-		// use up one of the "deleted" nodes
-		decisionIndexNumber = indexStructure.deletedRoot
-		indexStructure.deletedRoot = indexStructure.node[decisionIndexNumber].rightPointer
-		indexStructure.node[decisionIndexNumber] = placeholderNode
+		decisionIndexNumber = indexStructure.allocate(placeholderNode, previousIndexNumber)
 	}
 	//
-	if keyField[i:i+1] > string(indexStructure.node[keyPointer].key) {
+	if keyField[i] > indexStructure.nodeAt(keyPointer).key {
 		threadIndex := keyPointer
-		for !(indexStructure.node[threadIndex].status == 'L' || indexStructure.node[threadIndex].status == 'S') {
-			threadIndex = indexStructure.node[threadIndex].rightPointer
+		for !(indexStructure.nodeAt(threadIndex).status == 'L' || indexStructure.nodeAt(threadIndex).status == 'S') {
+			threadIndex = indexStructure.nodeAt(threadIndex).rightPointer
 		}
-		lastIndexNumber = indexStructure.node[threadIndex].rightPointer
-		indexStructure.node[threadIndex].rightPointer = decisionIndexNumber
+		lastIndexNumber = indexStructure.nodeAt(threadIndex).rightPointer
+		indexStructure.setRightPointer(threadIndex, decisionIndexNumber)
 	} else {
 		lastIndexNumber = decisionIndexNumber
 	}
 	//
-	linkIndexNumber := extend(keyField[i:], keyNumber, lastIndexNumber, indexStructure)
+	linkIndexNumber := extend(keyField[i:], keyNumber, lastIndexNumber, decisionIndexNumber, indexStructure)
 	//
-	if keyField[i:i+1] < string(indexStructure.node[keyPointer].key) {
-		indexStructure.node[decisionIndexNumber].leftPointer = linkIndexNumber
+	if keyField[i] < indexStructure.nodeAt(keyPointer).key {
+		indexStructure.setLeftPointer(decisionIndexNumber, linkIndexNumber)
 		byteArray := []byte(keyField[i : i+1])
-		indexStructure.node[decisionIndexNumber].key = byteArray[0]
-		indexStructure.node[decisionIndexNumber].rightPointer = keyPointer
+		indexStructure.setKey(decisionIndexNumber, byteArray[0])
+		indexStructure.setRightPointer(decisionIndexNumber, keyPointer)
 	} else {
-		indexStructure.node[decisionIndexNumber].leftPointer = keyPointer
-		indexStructure.node[decisionIndexNumber].key = indexStructure.node[keyPointer].key
-		indexStructure.node[decisionIndexNumber].rightPointer = linkIndexNumber
+		indexStructure.setLeftPointer(decisionIndexNumber, keyPointer)
+		indexStructure.setKey(decisionIndexNumber, indexStructure.nodeAt(keyPointer).key)
+		indexStructure.setRightPointer(decisionIndexNumber, linkIndexNumber)
 	}
 	//
 	if previousIndexNumber == nullIndexPointer {
 		indexStructure.indexRoot = decisionIndexNumber
 	} else {
-		if indexStructure.node[previousIndexNumber].status == 'D' &&
-			keyField[i:i+1] <= string(indexStructure.node[previousIndexNumber].key) ||
-			indexStructure.node[previousIndexNumber].status == 'L' ||
-			(indexStructure.node[previousIndexNumber].status == 'K' && duplicateFlag) {
-			indexStructure.node[previousIndexNumber].leftPointer = decisionIndexNumber
+		if indexStructure.nodeAt(previousIndexNumber).status == 'D' &&
+			keyField[i] <= indexStructure.nodeAt(previousIndexNumber).key ||
+			indexStructure.nodeAt(previousIndexNumber).status == 'L' ||
+			(indexStructure.nodeAt(previousIndexNumber).status == 'K' && duplicateFlag) {
+			indexStructure.setLeftPointer(previousIndexNumber, decisionIndexNumber)
 		} else {
-			indexStructure.node[previousIndexNumber].rightPointer = decisionIndexNumber
+			indexStructure.setRightPointer(previousIndexNumber, decisionIndexNumber)
 		}
 	}
 	return
@@ -788,6 +844,7 @@ func Insert(keyInput string, keyNumber int, indexStructure *Index) {
 //
 
 // Statistics scans the specified index structure and returns a structure of counts of the different node types
+// along with key, duplicate, memory and depth figures for sizing the index
 //
 func Statistics(indexStructure *Index) (result Statistic) {
 	var stack []int
@@ -802,6 +859,7 @@ func Statistics(indexStructure *Index) (result Statistic) {
 	result.NodeK = 0
 	result.NodeL = 0
 	result.NodeD = 0
+	result.NodeP = 0
 	keyPointer := indexStructure.indexRoot
 	//
 	for scanning := true; scanning; { // start scanning
@@ -809,27 +867,33 @@ func Statistics(indexStructure *Index) (result Statistic) {
 			scanning = false
 			break
 		}
-		switch indexStructure.node[keyPointer].status {
+		switch indexStructure.nodeAt(keyPointer).status {
 		//
 		case 'X':
 			result.NodeX++
 			stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
-			keyPointer = indexStructure.node[keyPointer].rightPointer
+			keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
+			goLeft = true
+			//
+		case 'P':
+			result.NodeP++
+			stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
+			keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 			goLeft = true
 			//
 		case 'R':
 			result.NodeR++
 			stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
-			keyPointer = indexStructure.node[keyPointer].rightPointer
+			keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 			goLeft = true
 			//
 		case 'D':
 			if goLeft {
 				result.NodeD++
 				stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
-				keyPointer = indexStructure.node[keyPointer].leftPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 			} else {
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				goLeft = true
 			}
 			//
@@ -837,16 +901,16 @@ func Statistics(indexStructure *Index) (result Statistic) {
 			if goLeft {
 				result.NodeK++
 				stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
-				keyPointer = indexStructure.node[keyPointer].leftPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 			} else {
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				goLeft = true
 			}
 			//
 		case 'S':
 			result.NodeS++
 			stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
-			keyPointer = indexStructure.node[keyPointer].rightPointer
+			keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 			if keyPointer != nullIndexPointer { // reset the stack
 				for stackPointer = 0; stack[stackPointer] != keyPointer; stackPointer++ {
 				}
@@ -858,9 +922,9 @@ func Statistics(indexStructure *Index) (result Statistic) {
 			if goLeft {
 				result.NodeL++
 				stack, stackPointer = pushStack(stack, keyPointer, stackPointer)
-				keyPointer = indexStructure.node[keyPointer].leftPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).leftPointer
 			} else { // going Right and staying Right
-				keyPointer = indexStructure.node[keyPointer].rightPointer
+				keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
 				if keyPointer != nullIndexPointer { // reset the stack
 					for stackPointer = 0; stack[stackPointer] != keyPointer; stackPointer++ {
 					}
@@ -870,10 +934,117 @@ func Statistics(indexStructure *Index) (result Statistic) {
 		}
 	} // end scanning
 	//
-	result.Active = result.NodeR + result.NodeS + result.NodeX + result.NodeK + result.NodeL + result.NodeD
+	result.Active = result.NodeR + result.NodeS + result.NodeX + result.NodeK + result.NodeL + result.NodeD +
+		result.NodeP
 	result.Depth = len(stack)
-	for x := indexStructure.deletedRoot; x != nullIndexPointer; x = indexStructure.node[x].rightPointer {
+	for x := indexStructure.deletedRoot; x != nullIndexPointer; x = indexStructure.nodeAt(x).rightPointer {
 		result.Deleted++
 	}
+	//
+	m := measurer{indexStructure: indexStructure, result: &result}
+	if indexStructure.indexRoot != nullIndexPointer {
+		m.measure(indexStructure.indexRoot, 0, 1, 0)
+	}
+	if result.Keys > 0 {
+		result.AverageKeyLength = float64(m.keyLengthTotal) / float64(result.Keys)
+	}
+	result.DecisionChain = make([]float64, len(m.chainTotal))
+	for level := range m.chainTotal {
+		result.DecisionChain[level] = float64(m.chainTotal[level]) / float64(m.chainCount[level])
+	}
+	result.BytesInUse = result.Active*indexStructure.nodeSize() + m.runBytes
+	result.BytesFree = result.Deleted * indexStructure.nodeSize()
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+type measurer struct {
+	indexStructure *Index
+	result         *Statistic
+	chainTotal     []int
+	chainCount     []int
+	keyLengthTotal int
+	runBytes       int
+}
+
+func (m *measurer) measure(keyPointer, level, depth, decisions int) {
+	// "level" is the character position, "depth" the number of nodes from the root and "decisions" the number of
+	// 'D' nodes passed since the last character
+	node := m.indexStructure.nodeAt(keyPointer)
+	if node.status == 'D' {
+		m.measure(node.leftPointer, level, depth+1, decisions+1)
+		m.measure(node.rightPointer, level, depth+1, decisions+1)
+		return
+	}
+	//
+	characters := 1
+	if node.status == 'P' {
+		characters = len(m.indexStructure.runs[node.leftPointer])
+		m.runBytes += characters + runHeaderSize
+	}
+	for i := 0; i < characters; i++ {
+		if len(m.chainTotal) <= level+i {
+			m.chainTotal = append(m.chainTotal, 0)
+			m.chainCount = append(m.chainCount, 0)
+		}
+		m.chainCount[level+i]++
+	}
+	m.chainTotal[level] += decisions
+	//
+	if node.status == 'P' {
+		m.measure(node.rightPointer, level+characters, depth+1, 0)
+		return
+	}
+	if node.status != 'X' { // end of a key
+		duplicates := 1
+		if node.status == 'K' || node.status == 'L' {
+			duplicates = countDuplicates(node.leftPointer, math.MaxInt, m.indexStructure)
+		}
+		m.result.Keys++
+		m.result.Entries += duplicates
+		if duplicates > m.result.LargestDuplicate {
+			m.result.LargestDuplicate = duplicates
+		}
+		m.keyLengthTotal += level + 1
+		if level+1 > m.result.MaximumKeyLength {
+			m.result.MaximumKeyLength = level + 1
+		}
+		for len(m.result.DepthHistogram) <= depth {
+			m.result.DepthHistogram = append(m.result.DepthHistogram, 0)
+		}
+		m.result.DepthHistogram[depth]++
+	}
+	//
+	if node.status == 'X' || node.status == 'R' || node.status == 'K' {
+		m.measure(node.rightPointer, level+1, depth+1, 0)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func countDuplicates(keyPointer int, limit int, indexStructure *Index) (count int) {
+	// counts the "index-numbers" in a duplicate branch, stopping once "limit" have been found
+	node := indexStructure.nodeAt(keyPointer)
+	switch node.status {
+	case 'D':
+		count = countDuplicates(node.leftPointer, limit, indexStructure)
+		if count < limit {
+			count += countDuplicates(node.rightPointer, limit-count, indexStructure)
+		}
+	case 'X':
+		count = countDuplicates(node.rightPointer, limit, indexStructure)
+	case 'R':
+		count = 1
+		if count < limit {
+			count += countDuplicates(node.rightPointer, limit-count, indexStructure)
+		}
+	case 'S':
+		count = 1
+	}
 	return
 }
@@ -0,0 +1,444 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// a testModel is what an index should hold, kept as a plain set of entries to check the index against
+//
+type testModel map[keyEntry]bool
+
+// a testConfig is one way of setting up an index -- every test that checks the node structure runs over all of them
+//
+type testConfig struct {
+	name       string
+	layout     Layout
+	allocation Allocation
+	trim       bool
+}
+
+var testConfigs = []testConfig{
+	{name: "wide", layout: WideLayout, allocation: AllocateLast},
+	{name: "compact", layout: CompactLayout, allocation: AllocateLast},
+	{name: "lowest-trim", layout: WideLayout, allocation: AllocateLowest, trim: true},
+	{name: "nearest", layout: CompactLayout, allocation: AllocateNearest},
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (c testConfig) index() *Index {
+	indexStructure := &Index{}
+	InitialiseLayout(indexStructure, c.layout)
+	SetAllocation(indexStructure, c.allocation, c.trim)
+	return indexStructure
+}
+
+func (m testModel) insert(keyInput string, keyNumber int) {
+	if keyField := trimKey(keyInput); keyField != "" {
+		m[keyEntry{key: keyField, keyNumber: keyNumber}] = true
+	}
+}
+
+func (m testModel) delete(keyInput string, keyNumber int) {
+	delete(m, keyEntry{key: trimKey(keyInput), keyNumber: keyNumber})
+}
+
+func (m testModel) entries() (entries []keyEntry) {
+	for entry := range m {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return
+}
+
+func (m testModel) numbers(wanted func(key string) bool) (indexes []int) {
+	for _, entry := range m.entries() {
+		if wanted(entry.key) {
+			indexes = append(indexes, entry.keyNumber)
+		}
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func checkIndex(t *testing.T, indexStructure *Index, m testModel) {
+	// the index must pass Verify and give back exactly the entries of the model, in order, by global and exact Search
+	t.Helper()
+	if valid, faults := Verify(indexStructure); !valid {
+		t.Fatalf("Verify: %v", faults)
+	}
+	_, got := Search("", false, indexStructure)
+	if want := m.numbers(func(string) bool { return true }); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("global Search gave %v, want %v", got, want)
+	}
+	for entry := range m {
+		matchFound, got := Search(entry.key, true, indexStructure)
+		want := m.numbers(func(key string) bool { return key == entry.key })
+		if !matchFound || fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("Search(%q) gave %v %v, want %v", entry.key, matchFound, got, want)
+		}
+	}
+}
+
+func randomKey(r *rand.Rand, alphabet string, maxLength int) string {
+	key := make([]byte, 1+r.Intn(maxLength))
+	for i := range key {
+		key[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return string(key)
+}
+
+func churn(t *testing.T, indexStructure *Index, m testModel, r *rand.Rand, alphabet string, maxLength, count int) {
+	// random Inserts and Deletes, a third of them Deletes of entries that are there, checking as it goes
+	t.Helper()
+	for x := 0; x < count; x++ {
+		if r.Intn(3) == 0 && len(m) > 0 {
+			entries := m.entries()
+			entry := entries[r.Intn(len(entries))]
+			Delete(entry.key, entry.keyNumber, indexStructure)
+			m.delete(entry.key, entry.keyNumber)
+		} else {
+			keyInput, keyNumber := randomKey(r, alphabet, maxLength), r.Intn(40)-5
+			Insert(keyInput, keyNumber, indexStructure)
+			m.insert(keyInput, keyNumber)
+		}
+		if x%25 == 0 {
+			checkIndex(t, indexStructure, m)
+		}
+	}
+	checkIndex(t, indexStructure, m)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestInsertDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		alphabet  string
+		maxLength int
+	}{
+		{name: "short", alphabet: "abc", maxLength: 3},
+		{name: "digits", alphabet: "0123456789", maxLength: 6},
+		{name: "long", alphabet: "ab/.", maxLength: 40}, // cut to MaxKeyLength
+		{name: "spaces", alphabet: "a b", maxLength: 5}, // trimmed
+		{name: "high bytes", alphabet: "a\x7f\x80\xc3\xa9\xff", maxLength: 4},
+	}
+	for _, c := range testConfigs {
+		for _, test := range tests {
+			t.Run(c.name+"/"+test.name, func(t *testing.T) {
+				for seed := int64(0); seed < 20; seed++ {
+					churn(t, c.index(), testModel{}, rand.New(rand.NewSource(seed)), test.alphabet, test.maxLength, 200)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteDuplicates(t *testing.T) {
+	// deleting from a duplicate set down to one number, and then none, whatever digits the numbers share
+	many := make([]int, 300)
+	for x := range many {
+		many[x] = x*7 - 100
+	}
+	manyDeleted := slices.Clone(many[1:])
+	rand.New(rand.NewSource(1)).Shuffle(len(manyDeleted), func(x, y int) {
+		manyDeleted[x], manyDeleted[y] = manyDeleted[y], manyDeleted[x]
+	})
+	tests := []struct {
+		name    string
+		numbers []int
+		delete  []int
+	}{
+		{name: "shared leading digit", numbers: []int{20, 22}, delete: []int{20}},
+		{name: "shared leading digit other", numbers: []int{20, 22}, delete: []int{22}},
+		{name: "one a prefix of the other", numbers: []int{2, 20}, delete: []int{2}},
+		{name: "one a prefix of the other kept", numbers: []int{2, 20}, delete: []int{20}},
+		{name: "three down to two sharing", numbers: []int{20, 22, 5}, delete: []int{5, 22}},
+		{name: "long shared digits", numbers: []int{12345, 12346, 1234}, delete: []int{1234, 12345}},
+		{name: "negative", numbers: []int{-1, -12}, delete: []int{-12}},
+		{name: "all", numbers: []int{20, 22}, delete: []int{20, 22}},
+		{name: "many down to one", numbers: many, delete: manyDeleted},
+	}
+	for _, c := range testConfigs {
+		for _, test := range tests {
+			t.Run(c.name+"/"+test.name, func(t *testing.T) {
+				for _, keyField := range []string{"k", "key", "a/key"} {
+					indexStructure, m := c.index(), testModel{}
+					Insert(keyField+"z", 1, indexStructure) // a longer key, so the duplicates sit on a 'K' node too
+					m.insert(keyField+"z", 1)
+					for _, keyNumber := range test.numbers {
+						Insert(keyField, keyNumber, indexStructure)
+						m.insert(keyField, keyNumber)
+					}
+					for x, keyNumber := range test.delete {
+						Delete(keyField, keyNumber, indexStructure)
+						m.delete(keyField, keyNumber)
+						if x%25 == 0 || len(test.delete)-x < 5 {
+							checkIndex(t, indexStructure, m)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	// keys come back in the order of their bytes, taken as unsigned
+	tests := []struct {
+		name string
+		keys []string
+	}{
+		{name: "ascii", keys: []string{"b", "a", "ab", "abc", "B", "0"}},
+		{name: "accents", keys: []string{"é", "e", "z", "ée", "è"}},
+		{name: "high bytes", keys: []string{"\xff", "\x80", "\x7f", "a\xff", "a\x01", "a"}},
+	}
+	for _, c := range testConfigs {
+		for _, test := range tests {
+			t.Run(c.name+"/"+test.name, func(t *testing.T) {
+				indexStructure, m := c.index(), testModel{}
+				for x, keyInput := range test.keys {
+					Insert(keyInput, x, indexStructure)
+					m.insert(keyInput, x)
+				}
+				checkIndex(t, indexStructure, m)
+			})
+		}
+	}
+}
+
+func TestNonASCIIKeys(t *testing.T) {
+	// a key's characters are compared with the nodes as bytes, so a byte above 0x7f is found again, and is not taken
+	// for the character it would stand for on its own, "\xa9" for "©"
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			indexStructure := c.index()
+			Insert("é", 1, indexStructure)
+			Insert("\xa9", 2, indexStructure)
+			Insert("é", 3, indexStructure)
+			Insert("éa", 4, indexStructure)
+			Insert("©", 5, indexStructure)
+			for _, search := range []struct {
+				keyInput        string
+				searchPrecisely bool
+				want            []int
+			}{
+				{"é", true, []int{1, 3}},
+				{"é", false, []int{1, 3, 4}},
+				{"\xc3", false, []int{1, 3, 4}},
+				{"\xc3", true, nil},
+				{"\xa9", true, []int{2}},
+				{"©", true, []int{5}},
+				{"\xc2", false, []int{5}},
+			} {
+				_, got := Search(search.keyInput, search.searchPrecisely, indexStructure)
+				if fmt.Sprint(got) != fmt.Sprint(search.want) {
+					t.Errorf("Search(%q, %v) gave %v, want %v", search.keyInput, search.searchPrecisely, got,
+						search.want)
+				}
+			}
+			Delete("é", 1, indexStructure)
+			Delete("\xa9", 2, indexStructure)
+			Delete("éa", 4, indexStructure)
+			m := testModel{}
+			m.insert("é", 3)
+			m.insert("©", 5)
+			checkIndex(t, indexStructure, m)
+		})
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestVerifyFindsDamage(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(indexStructure *Index)
+		fault  string
+	}{
+		{name: "pointer out of range", fault: "out of range",
+			damage: func(indexStructure *Index) { indexStructure.setRightPointer(indexStructure.indexRoot, 1<<20) }},
+		{name: "bad status", fault: "status",
+			damage: func(indexStructure *Index) { indexStructure.setStatus(indexStructure.indexRoot, '?') }},
+		{name: "free list loop", fault: "free list loops",
+			damage: func(indexStructure *Index) {
+				indexStructure.setRightPointer(indexStructure.deletedRoot, indexStructure.deletedRoot)
+			}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var indexStructure Index
+			Initialise(&indexStructure)
+			for x, keyInput := range []string{"alpha", "alps", "beta", "bet", "gamma"} {
+				Insert(keyInput, x, &indexStructure)
+			}
+			Delete("gamma", 4, &indexStructure)
+			test.damage(&indexStructure)
+			valid, faults := Verify(&indexStructure)
+			if valid || !strings.Contains(strings.Join(faults, "\n"), test.fault) {
+				t.Fatalf("Verify gave %v %v, want a fault about %q", valid, faults, test.fault)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				r := rand.New(rand.NewSource(seed))
+				indexStructure, m := c.index(), testModel{}
+				churn(t, indexStructure, m, r, "abcd", 5, 150)
+				//
+				// an undamaged index is rebuilt whole
+				report := Recover(indexStructure)
+				if report.Recovered != len(m) || len(report.Lost) != 0 || len(report.Damage) != 0 {
+					t.Fatalf("seed %d: undamaged index gave %+v, want %d entries", seed, report, len(m))
+				}
+				checkIndex(t, indexStructure, m)
+				//
+				// a damaged one gives back a valid index holding only entries that were there
+				if indexStructure.nodeCount() < 4 {
+					continue
+				}
+				indexStructure.setRightPointer(r.Intn(indexStructure.nodeCount()), 1<<20)
+				report = Recover(indexStructure)
+				if valid, faults := Verify(indexStructure); !valid {
+					t.Fatalf("seed %d: recovered index fails Verify: %v", seed, faults)
+				}
+				recovered := testModel{}
+				for entry := range m {
+					if matchFound, indexes := Search(entry.key, true, indexStructure); matchFound {
+						for _, keyNumber := range indexes {
+							if !m[keyEntry{key: entry.key, keyNumber: keyNumber}] {
+								t.Fatalf("seed %d: recovered %q %d, which was never inserted", seed, entry.key,
+									keyNumber)
+							}
+							recovered[keyEntry{key: entry.key, keyNumber: keyNumber}] = true
+						}
+					}
+				}
+				if len(recovered) != report.Recovered || report.Recovered+len(report.Lost) > len(m) {
+					t.Fatalf("seed %d: report %+v does not match %d entries found of %d", seed, report,
+						len(recovered), len(m))
+				}
+				checkIndex(t, indexStructure, recovered)
+			}
+		})
+	}
+}
+
+func TestRecoverFreeListOverlap(t *testing.T) {
+	// a free list that runs through a node of the index is followed to its end, so the free nodes past the overlap
+	// are neither counted as unreachable nor scanned as detached branches
+	var indexStructure Index
+	Initialise(&indexStructure)
+	m := testModel{}
+	for x, keyInput := range []string{"alpha", "alps", "beta", "bet", "gamma", "delta", "deltas"} {
+		Insert(keyInput, x, &indexStructure)
+		m.insert(keyInput, x)
+	}
+	for x, keyInput := range map[int]string{4: "gamma", 6: "deltas"} {
+		Delete(keyInput, x, &indexStructure)
+		m.delete(keyInput, x)
+	}
+	var free []int
+	for x := indexStructure.deletedRoot; x != nullIndexPointer; x = indexStructure.nodeAt(x).rightPointer {
+		free = append(free, x)
+	}
+	if len(free) < 3 {
+		t.Fatalf("free list is %v, want at least three nodes", free)
+	}
+	leaf := nullIndexPointer
+	for x := 0; x < indexStructure.nodeCount() && leaf == nullIndexPointer; x++ {
+		if indexStructure.nodeAt(x).status == 'S' && !slices.Contains(free, x) {
+			leaf = x
+		}
+	}
+	// the thread of the leaf is ignored by Recover, so only the free list is damaged
+	indexStructure.setRightPointer(free[0], leaf)
+	indexStructure.setRightPointer(leaf, free[1])
+	//
+	report := Recover(&indexStructure)
+	if report.Recovered != len(m) || report.Unreachable != 0 || len(report.Lost) != 0 || len(report.Damage) != 1 ||
+		!strings.Contains(report.Damage[0], "both in the index and on the free list") {
+		t.Fatalf("Recover gave %+v, want %d entries and the overlap", report, len(m))
+	}
+	checkIndex(t, &indexStructure, m)
+}
+
+func TestStatistics(t *testing.T) {
+	// "a" and "b" hang off a decision node at the root, "ab" is held three times and "cde" of "bcdef" is a run
+	var indexStructure Index
+	Initialise(&indexStructure)
+	for x, keyInput := range []string{"a", "ab", "ab", "ab", "abc", "b", "bcdef"} {
+		Insert(keyInput, x, &indexStructure)
+	}
+	want := Statistic{Active: 12, Deleted: 2, Depth: 6, NodeR: 2, NodeS: 5, NodeK: 1, NodeD: 3, NodeP: 1,
+		Keys: 5, Entries: 7, LargestDuplicate: 3, AverageKeyLength: 2.4, MaximumKeyLength: 5,
+		DecisionChain: []float64{1, 0, 0, 0, 0}, DepthHistogram: []int{0, 0, 2, 1, 2},
+		BytesInUse: 12*indexStructure.nodeSize() + len("cde") + runHeaderSize, BytesFree: 2 * indexStructure.nodeSize()}
+	if got := Statistics(&indexStructure); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statistics gave %+v, want %+v", got, want)
+	}
+	if got := Statistics(&Index{indexRoot: nullIndexPointer, deletedRoot: nullIndexPointer}); got.Keys != 0 ||
+		got.Entries != 0 || got.AverageKeyLength != 0 || len(got.DecisionChain) != 0 || len(got.DepthHistogram) != 0 {
+		t.Errorf("Statistics of an empty index gave %+v", got)
+	}
+}
+
+func TestStatisticsAgainstModel(t *testing.T) {
+	// the key and duplicate figures match those of the entries the index holds, whatever its layout
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			for seed := int64(0); seed < 10; seed++ {
+				indexStructure, m := c.index(), testModel{}
+				churn(t, indexStructure, m, rand.New(rand.NewSource(seed)), "abc", 8, 150)
+				duplicates := map[string]int{}
+				for entry := range m {
+					duplicates[entry.key]++
+				}
+				var want Statistic
+				keyLengthTotal := 0
+				for keyField, count := range duplicates {
+					want.Keys++
+					want.Entries += count
+					want.LargestDuplicate = max(want.LargestDuplicate, count)
+					want.MaximumKeyLength = max(want.MaximumKeyLength, len(keyField))
+					keyLengthTotal += len(keyField)
+				}
+				if want.Keys > 0 {
+					want.AverageKeyLength = float64(keyLengthTotal) / float64(want.Keys)
+				}
+				got := Statistics(indexStructure)
+				if got.Keys != want.Keys || got.Entries != want.Entries ||
+					got.LargestDuplicate != want.LargestDuplicate || got.MaximumKeyLength != want.MaximumKeyLength ||
+					got.AverageKeyLength != want.AverageKeyLength {
+					t.Fatalf("seed %d: Statistics gave %+v, want %+v", seed, got, want)
+				}
+				histogramTotal := 0
+				for _, keys := range got.DepthHistogram {
+					histogramTotal += keys
+				}
+				if histogramTotal != got.Keys || len(got.DecisionChain) != got.MaximumKeyLength ||
+					got.Active+got.Deleted != indexStructure.nodeCount() {
+					t.Fatalf("seed %d: Statistics gave %+v for %d nodes", seed, got, indexStructure.nodeCount())
+				}
+			}
+		})
+	}
+}
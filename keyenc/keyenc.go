@@ -0,0 +1,287 @@
+// Package keyenc turns tuples of values into index keys whose byte order is the order of the tuples, and back again
+//
+// every component starts with a tag byte and ends with a byte, neither of which is ever white space, so the keys
+// survive the trimming done by the index, and the key of a tuple's leading components is a prefix of the key of the
+// whole tuple -- a global Search on Encode(surname) finds every (surname, forename, date-of-birth) key filed under
+// that surname
+package keyenc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/apwoodhouse/key"
+)
+
+// tags in the order different kinds of value sort in a component: false, true, signed integers, unsigned integers,
+// floats, times, strings -- none of them is white space, 0x09 to 0x0d or 0x20, which the index would trim from the
+// start of a key
+const (
+	tagFalse  = 0x02
+	tagTrue   = 0x03
+	tagInt    = 0x2a // zero -- a negative integer of n bytes is tagInt-n and a positive one is tagInt+n
+	tagUint   = 0x34 // plus the number of bytes
+	tagFloat  = 0x40
+	tagTime   = 0x48
+	tagString = 0x50
+	//
+	endOfValue  = 0x00 // closes every integer, float, time and string
+	escapedNull = 0xff // follows a 0x00 that is part of a string
+)
+
+var (
+	// ErrTooLong is returned by Encode when the key would be longer than the index keeps
+	ErrTooLong = errors.New("keyenc: key longer than the index keeps")
+	// ErrUnsupported is returned by Encode for a value of a type it cannot encode
+	ErrUnsupported = errors.New("keyenc: unsupported type")
+	// ErrCorrupt is returned by Decode for a key that Encode could not have produced
+	ErrCorrupt = errors.New("keyenc: corrupt key")
+)
+
+var (
+	earliestTime = time.Unix(0, math.MinInt64)
+	latestTime   = time.Unix(0, math.MaxInt64)
+)
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Encode returns the key of a tuple -- the values may be strings, byte slices, booleans, signed or unsigned integers
+// of any size, floats and times
+// integers take only as many bytes as their size needs, floats and times take eight, and a time keeps its instant to
+// the nanosecond between the years 1678 and 2262 but not its location
+// an error is returned for any other type of value, for a time out of range, or if the key would be longer than
+// key.MaxKeyLength
+//
+func Encode(values ...interface{}) (keyField string, err error) {
+	var b []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case bool:
+			if v {
+				b = append(b, tagTrue)
+			} else {
+				b = append(b, tagFalse)
+			}
+		case int:
+			b = appendInt(b, int64(v))
+		case int8:
+			b = appendInt(b, int64(v))
+		case int16:
+			b = appendInt(b, int64(v))
+		case int32:
+			b = appendInt(b, int64(v))
+		case int64:
+			b = appendInt(b, v)
+		case uint:
+			b = appendUint(b, uint64(v))
+		case uint8:
+			b = appendUint(b, uint64(v))
+		case uint16:
+			b = appendUint(b, uint64(v))
+		case uint32:
+			b = appendUint(b, uint64(v))
+		case uint64:
+			b = appendUint(b, v)
+		case float32:
+			b = appendFloat(b, float64(v))
+		case float64:
+			b = appendFloat(b, v)
+		case time.Time:
+			if v.Before(earliestTime) || v.After(latestTime) {
+				return "", fmt.Errorf("keyenc: time %v out of range", v)
+			}
+			b = append(b, tagTime)
+			b = binary.BigEndian.AppendUint64(b, uint64(v.UnixNano())^1<<63)
+			b = append(b, endOfValue)
+		case string:
+			b = appendString(b, v)
+		case []byte:
+			b = appendString(b, string(v))
+		default:
+			return "", fmt.Errorf("%w %T", ErrUnsupported, value)
+		}
+	}
+	if len(b) > key.MaxKeyLength {
+		return "", ErrTooLong
+	}
+	keyField = string(b)
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func appendInt(b []byte, v int64) []byte {
+	// a negative integer is stored as the complement of its size, so that larger sizes sort first
+	if v >= 0 {
+		n := byteCount(uint64(v))
+		b = append(b, byte(tagInt+n))
+		return appendBytes(b, uint64(v), n)
+	}
+	size := uint64(-(v + 1)) + 1 // without overflowing on math.MinInt64
+	n := byteCount(size)
+	b = append(b, byte(tagInt-n))
+	return appendBytes(b, ^size, n)
+}
+
+func appendUint(b []byte, v uint64) []byte {
+	n := byteCount(v)
+	b = append(b, byte(tagUint+n))
+	return appendBytes(b, v, n)
+}
+
+func appendFloat(b []byte, v float64) []byte {
+	// the sign bit is flipped on positive numbers and every bit on negative ones
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	b = append(b, tagFloat)
+	b = binary.BigEndian.AppendUint64(b, bits)
+	return append(b, endOfValue)
+}
+
+func appendString(b []byte, v string) []byte {
+	b = append(b, tagString)
+	for i := 0; i < len(v); i++ {
+		b = append(b, v[i])
+		if v[i] == endOfValue {
+			b = append(b, escapedNull)
+		}
+	}
+	return append(b, endOfValue)
+}
+
+func appendBytes(b []byte, v uint64, n int) []byte {
+	// the last "n" bytes of "v", high byte first, then the end of the value
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return append(b, endOfValue)
+}
+
+func byteCount(v uint64) (n int) {
+	for ; v != 0; v >>= 8 {
+		n++
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Decode returns the tuple a key was encoded from -- integers come back as int64 or uint64 depending on whether they
+// were signed, floats as float64, times as time.Time in UTC, and strings and byte slices as strings
+// a key cut short by the index, or one not made by Encode, returns ErrCorrupt
+//
+func Decode(keyField string) (values []interface{}, err error) {
+	for i := 0; i < len(keyField); {
+		tag := keyField[i]
+		i++
+		switch {
+		case tag == tagFalse || tag == tagTrue:
+			values = append(values, tag == tagTrue)
+		//
+		case tag >= tagInt-8 && tag <= tagInt+8:
+			n := int(tag) - tagInt
+			if n < 0 {
+				n = -n
+			}
+			var v uint64
+			if v, i, err = readBytes(keyField, i, n); err != nil {
+				return nil, err
+			}
+			if tag >= tagInt { // only the shortest form of a number is accepted
+				if v > math.MaxInt64 || byteCount(v) != n {
+					return nil, ErrCorrupt
+				}
+				values = append(values, int64(v))
+			} else {
+				size := ^v
+				if n < 8 {
+					size &= 1<<(8*n) - 1
+				}
+				if byteCount(size) != n {
+					return nil, ErrCorrupt
+				}
+				values = append(values, -int64(size-1)-1)
+			}
+		//
+		case tag >= tagUint && tag <= tagUint+8:
+			var v uint64
+			if v, i, err = readBytes(keyField, i, int(tag)-tagUint); err != nil {
+				return nil, err
+			}
+			if byteCount(v) != int(tag)-tagUint {
+				return nil, ErrCorrupt
+			}
+			values = append(values, v)
+		//
+		case tag == tagFloat || tag == tagTime:
+			if i+9 > len(keyField) || keyField[i+8] != endOfValue {
+				return nil, ErrCorrupt
+			}
+			bits := binary.BigEndian.Uint64([]byte(keyField[i : i+8]))
+			i += 9
+			if tag == tagTime {
+				values = append(values, time.Unix(0, int64(bits^1<<63)).UTC())
+				break
+			}
+			if bits&(1<<63) != 0 {
+				bits &^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			values = append(values, math.Float64frombits(bits))
+		//
+		case tag == tagString:
+			var v []byte
+			for {
+				if i >= len(keyField) {
+					return nil, ErrCorrupt
+				}
+				if keyField[i] == endOfValue {
+					if i+1 < len(keyField) && keyField[i+1] == escapedNull {
+						v = append(v, endOfValue)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				v = append(v, keyField[i])
+				i++
+			}
+			values = append(values, string(v))
+		//
+		default:
+			return nil, ErrCorrupt
+		}
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func readBytes(keyField string, i, n int) (v uint64, next int, err error) {
+	// reads an "n" byte integer and the end of the value
+	if i+n >= len(keyField) || keyField[i+n] != endOfValue {
+		return 0, i, ErrCorrupt
+	}
+	for j := 0; j < n; j++ {
+		v = v<<8 | uint64(keyField[i+j])
+	}
+	next = i + n + 1
+	return
+}
@@ -0,0 +1,212 @@
+package keyenc
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apwoodhouse/key"
+)
+
+var boundaryInts = []int64{math.MinInt64, math.MinInt64 + 1, -1 << 56, -1<<56 - 1, -1 << 48, -1 << 40, -1 << 32,
+	-1 << 24, -1 << 16, -257, -256, -255, -2, -1, 0, 1, 255, 256, 1 << 16, 1 << 24, 1 << 32, 1 << 40, 1 << 48,
+	1 << 56, math.MaxInt64}
+
+var boundaryUints = []uint64{0, 1, 255, 256, 1 << 16, 1 << 24, 1 << 32, 1 << 40, 1 << 48, 1 << 56, math.MaxUint64}
+
+func compareValues(a, b interface{}) int {
+	// the order Encode promises: false, true, signed integers, unsigned integers, floats, times, strings
+	rank := func(v interface{}) int {
+		switch v := v.(type) {
+		case bool:
+			if v {
+				return 1
+			}
+			return 0
+		case int64:
+			return 2
+		case uint64:
+			return 3
+		case float64:
+			return 4
+		case time.Time:
+			return 5
+		}
+		return 6
+	}
+	if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+		return c
+	}
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case uint64:
+		return cmp.Compare(a, b.(uint64))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return cmp.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func compareTuples(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func randomValue(r *rand.Rand) interface{} {
+	switch r.Intn(7) {
+	case 0:
+		return r.Intn(2) == 0
+	case 1:
+		return boundaryInts[r.Intn(len(boundaryInts))] + int64(r.Intn(3)-1)
+	case 2:
+		return boundaryUints[r.Intn(len(boundaryUints))] - uint64(r.Intn(2))
+	case 3:
+		return []float64{math.Inf(-1), -1e300, -1.5, -0.5, 0, 0.5, 1.5, 1e300, math.Inf(1)}[r.Intn(9)]
+	case 4:
+		return time.Unix(int64(r.Intn(2000000000))-1000000000, int64(r.Intn(1000000000))).UTC()
+	}
+	return []string{"", " ", "\t", "a", "a ", "a\x00", "a\x00b", "\x00", "\n", "ab", "b", " b", "\xff"}[r.Intn(13)]
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   []interface{}
+	}{
+		{name: "booleans", values: []interface{}{false, true}},
+		{name: "small integers", values: []interface{}{int8(-1), int16(0), int32(7), 8}, want: []interface{}{int64(-1),
+			int64(0), int64(7), int64(8)}},
+		{name: "unsigned zero", values: []interface{}{uint(0), uint8(0)}, want: []interface{}{uint64(0), uint64(0)}},
+		{name: "extreme integers", values: []interface{}{int64(math.MinInt64), int64(math.MaxInt64)}},
+		{name: "extreme unsigned", values: []interface{}{uint64(math.MaxUint64), int64(-1 << 56)}},
+		{name: "floats", values: []interface{}{float32(1.5), -0.25, math.Inf(-1)}, want: []interface{}{1.5, -0.25,
+			math.Inf(-1)}},
+		{name: "time", values: []interface{}{time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)}},
+		{name: "strings", values: []interface{}{"", " a ", "\x00\xff", []byte("b")}, want: []interface{}{"", " a ",
+			"\x00\xff", "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyField, err := Encode(test.values...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(keyField) != keyField {
+				t.Fatalf("key %q has white space the index would trim", keyField)
+			}
+			want := test.want
+			if want == nil {
+				want = test.values
+			}
+			if got, err := Decode(keyField); err != nil || !reflect.DeepEqual(got, want) {
+				t.Fatalf("Decode gave %#v %v, want %#v", got, err, want)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Encode(struct{}{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Encode of a struct gave %v", err)
+	}
+	if _, err := Encode(strings.Repeat("a", key.MaxKeyLength)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode of a long string gave %v", err)
+	}
+	if _, err := Encode(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Errorf("Encode of a time out of range gave no error")
+	}
+	keyField, _ := Encode("abc", 300)
+	for _, corrupt := range []string{keyField[:len(keyField)-1], keyField[:3], "\x01", string([]byte{tagInt + 2, 0,
+		1, 0})} {
+		if _, err := Decode(corrupt); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Decode(%q) gave %v", corrupt, err)
+		}
+	}
+}
+
+func TestIndexOrder(t *testing.T) {
+	// keys filed in an index come back from a global Search in the order of their tuples, and a global Search on the
+	// key of the leading components finds every tuple starting with them
+	r := rand.New(rand.NewSource(1))
+	var tuples [][]interface{}
+	for _, v := range boundaryInts {
+		tuples = append(tuples, []interface{}{v})
+	}
+	for _, v := range boundaryUints {
+		tuples = append(tuples, []interface{}{v, "x"})
+	}
+	for x := 0; x < 2000; x++ {
+		tuple := make([]interface{}, 1+r.Intn(3))
+		for i := range tuple {
+			tuple[i] = randomValue(r)
+		}
+		tuples = append(tuples, tuple)
+	}
+	//
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	keys := map[string]int{}
+	var distinct [][]interface{}
+	for _, tuple := range tuples {
+		keyField, err := Encode(tuple...)
+		if errors.Is(err, ErrTooLong) {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if _, exists := keys[keyField]; exists {
+			continue
+		}
+		keys[keyField] = len(distinct)
+		key.Insert(keyField, len(distinct), &indexStructure)
+		distinct = append(distinct, tuple)
+	}
+	want := make([]int, len(distinct))
+	for x := range want {
+		want[x] = x
+	}
+	slices.SortFunc(want, func(a, b int) int { return compareTuples(distinct[a], distinct[b]) })
+	if _, got := key.Search("", false, &indexStructure); fmt.Sprint(got) != fmt.Sprint(want) {
+		for x := range got {
+			if x >= len(want) || got[x] != want[x] {
+				t.Fatalf("global Search is out of order at %d: %#v before %#v", x, distinct[got[x]], distinct[want[x]])
+			}
+		}
+		t.Fatalf("global Search gave %d keys, want %d", len(got), len(want))
+	}
+	//
+	for _, leading := range distinct[:50] {
+		prefix, _ := Encode(leading[0])
+		var want []int
+		for x, tuple := range distinct {
+			if compareValues(tuple[0], leading[0]) == 0 {
+				want = append(want, x)
+			}
+		}
+		slices.SortFunc(want, func(a, b int) int { return compareTuples(distinct[a], distinct[b]) })
+		if _, got := key.Search(prefix, false, &indexStructure); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("global Search on %#v gave %v, want %v", leading[0], got, want)
+		}
+	}
+}
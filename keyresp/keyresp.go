@@ -0,0 +1,366 @@
+// Package keyresp serves an index over the Redis protocol, RESP, so that redis-cli and Redis client libraries can
+// drive it
+//
+//	KINSERT key n               1 if the entry was added, 0 if it was already there
+//	KDEL key n                  1 if the entry was removed, 0 if it was not there
+//	KSEARCH key [EXACT|PREFIX]  the "index-numbers" filed under key, or under every key starting with it
+//	KSTATS                      the Statistic of the index, one "name:value" line for each field
+//	PING [message]
+//	QUIT
+//
+// commands may be sent as RESP arrays or as inline lines of words, and a client may send any number before reading
+// the replies -- replies are written in the order of the commands and flushed once every command received so far
+// has been answered
+package keyresp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apwoodhouse/key"
+)
+
+const (
+	maxArguments = 1024
+	maxBulk      = 1 << 20 // longest argument taken, in bytes
+	maxCommand   = 4 << 20 // longest command taken, all its arguments together, in bytes
+	maxLine      = 1 << 16 // longest line taken, inline command or length, in bytes
+)
+
+// ErrClosed is returned by Serve once the server has been closed
+//
+var ErrClosed = errors.New("keyresp: closed")
+
+// a protocolError is a request that cannot be read, after which the connection is dropped
+//
+type protocolError string
+
+func (e protocolError) Error() string { return "Protocol error: " + string(e) }
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Server answers RESP commands on an index
+// the index should only be used through the Server, or inside Update, while it is serving
+//
+type Server struct {
+	mu        sync.Mutex
+	index     *key.Index
+	listeners []net.Listener
+	conns     map[net.Conn]bool
+	closed    bool
+}
+
+// New makes a Server for the specified index
+//
+func New(indexStructure *key.Index) *Server {
+	return &Server{index: indexStructure, conns: make(map[net.Conn]bool)}
+}
+
+// Update calls "use" with the index while no command is being carried out
+//
+func (s *Server) Update(use func(indexStructure *key.Index)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	use(s.index)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Serve accepts clients on "listener" until it fails or the server is closed
+//
+func (s *Server) Serve(listener net.Listener) (err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrClosed
+	}
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+	for {
+		var conn net.Conn
+		if conn, err = listener.Accept(); err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.closed {
+				err = ErrClosed
+			}
+			return
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn answers the commands of the client at the other end of "conn" until it quits or goes away, and then
+// closes it
+//
+func (s *Server) ServeConn(conn net.Conn) (err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return ErrClosed
+	}
+	s.conns[conn] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	//
+	r := bufio.NewReaderSize(conn, maxLine)
+	w := bufio.NewWriter(conn)
+	for {
+		var args []string
+		if args, err = readCommand(r); err != nil {
+			if e, bad := err.(protocolError); bad {
+				writeError(w, "ERR "+e.Error())
+				w.Flush()
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		quit := len(args) > 0 && strings.EqualFold(args[0], "QUIT")
+		if quit {
+			writeSimple(w, "OK")
+		} else if len(args) > 0 {
+			s.execute(w, args)
+		}
+		if r.Buffered() == 0 || quit { // nothing more sent yet, so the client may be waiting for the replies
+			if err = w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// Close stops the server -- its listeners are closed and its clients disconnected
+//
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func readLine(r *bufio.Reader) (line string, err error) {
+	// a line has to fit in the buffer of "r", made maxLine long, so a client cannot have one held without end
+	slice, err := r.ReadSlice('\n')
+	switch {
+	case err == bufio.ErrBufferFull:
+		return "", protocolError("line too long")
+	case err == io.EOF && len(slice) > 0:
+		return "", io.ErrUnexpectedEOF
+	case err != nil:
+		return
+	}
+	return strings.TrimSuffix(string(slice[:len(slice)-1]), "\r"), nil
+}
+
+func readLength(r *bufio.Reader, marker byte, limit int) (length int, err error) {
+	line, err := readLine(r)
+	if err != nil {
+		return
+	}
+	if len(line) == 0 || line[0] != marker {
+		return 0, protocolError("expected '" + string(marker) + "'")
+	}
+	if length, err = strconv.Atoi(line[1:]); err != nil || length < 0 || length > limit {
+		return 0, protocolError("invalid length")
+	}
+	return
+}
+
+func readCommand(r *bufio.Reader) (args []string, err error) {
+	// an array of bulk strings, or an inline command of words separated by spaces
+	first, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] != '*' {
+		var line string
+		if line, err = readLine(r); err != nil {
+			return
+		}
+		return strings.Fields(line), nil
+	}
+	count, err := readLength(r, '*', maxArguments)
+	if err != nil {
+		return
+	}
+	args = make([]string, count)
+	var bulk bytes.Buffer
+	left := maxCommand
+	for x := range args {
+		var length int
+		if length, err = readLength(r, '$', maxBulk); err != nil {
+			return
+		}
+		if length > left {
+			return nil, protocolError("command too long")
+		}
+		left -= length
+		// read into a buffer that grows as the bytes arrive, so a length that is a lie costs nothing
+		bulk.Reset()
+		if _, err = io.CopyN(&bulk, r, int64(length)+2); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		if !bytes.HasSuffix(bulk.Bytes(), []byte("\r\n")) {
+			return nil, protocolError("bulk string not ended by CRLF")
+		}
+		args[x] = string(bulk.Bytes()[:length])
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func writeSimple(w *bufio.Writer, text string) {
+	w.WriteString("+" + text + "\r\n")
+}
+
+func writeError(w *bufio.Writer, text string) {
+	w.WriteString("-" + text + "\r\n")
+}
+
+func writeInteger(w *bufio.Writer, value int) {
+	w.WriteString(":" + strconv.Itoa(value) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, text string) {
+	w.WriteString("$" + strconv.Itoa(len(text)) + "\r\n" + text + "\r\n")
+}
+
+func writeIntegers(w *bufio.Writer, values []int) {
+	w.WriteString("*" + strconv.Itoa(len(values)) + "\r\n")
+	for _, value := range values {
+		writeInteger(w, value)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (s *Server) execute(w *bufio.Writer, args []string) {
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "KINSERT", "KDEL":
+		if len(args) != 3 {
+			writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command")
+			return
+		}
+		keyNumber, err := strconv.Atoi(args[2])
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		s.mu.Lock()
+		result := key.ApplyBatch([]key.Op{{Delete: name == "KDEL", Key: args[1], KeyNumber: keyNumber}}, s.index)[0]
+		s.mu.Unlock()
+		switch result {
+		case key.OpInserted, key.OpDeleted:
+			writeInteger(w, 1)
+		case key.OpExists, key.OpNotFound:
+			writeInteger(w, 0)
+		default:
+			writeError(w, "ERR blank key, or number too large for the index")
+		}
+	//
+	case "KSEARCH":
+		searchPrecisely := true
+		switch {
+		case len(args) == 3 && strings.EqualFold(args[2], "PREFIX"):
+			searchPrecisely = false
+		case len(args) == 3 && strings.EqualFold(args[2], "EXACT"), len(args) == 2:
+		case len(args) == 3:
+			writeError(w, "ERR syntax error")
+			return
+		default:
+			writeError(w, "ERR wrong number of arguments for 'ksearch' command")
+			return
+		}
+		if strings.TrimSpace(args[1]) == "" {
+			writeIntegers(w, nil)
+			return
+		}
+		s.mu.Lock()
+		_, indexes := key.Search(args[1], searchPrecisely, s.index)
+		s.mu.Unlock()
+		writeIntegers(w, indexes)
+	//
+	case "KSTATS":
+		s.mu.Lock()
+		result := key.Statistics(s.index)
+		s.mu.Unlock()
+		writeBulk(w, statistics(result))
+	//
+	case "PING":
+		switch len(args) {
+		case 1:
+			writeSimple(w, "PONG")
+		case 2:
+			writeBulk(w, args[1])
+		default:
+			writeError(w, "ERR wrong number of arguments for 'ping' command")
+		}
+	//
+	case "COMMAND": // asked by redis-cli when it starts
+		w.WriteString("*0\r\n")
+	//
+	default:
+		writeError(w, "ERR unknown command '"+args[0]+"'")
+	}
+}
+
+func statistics(result key.Statistic) string {
+	// every field of the Statistic as "name:value", in the style of the Redis INFO command
+	var b strings.Builder
+	value := reflect.ValueOf(result)
+	for x := 0; x < value.NumField(); x++ {
+		field := value.Field(x)
+		b.WriteString(value.Type().Field(x).Name + ":")
+		if field.Kind() == reflect.Slice {
+			for y := 0; y < field.Len(); y++ {
+				if y > 0 {
+					b.WriteByte(',')
+				}
+				fmt.Fprint(&b, field.Index(y).Interface())
+			}
+		} else {
+			fmt.Fprint(&b, field.Interface())
+		}
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package keyresp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apwoodhouse/key"
+)
+
+func exchange(t *testing.T, s *Server, request string) (reply string, err error) {
+	// sends "request" to the server over a net.Pipe, all at once, and returns everything it answers before it closes
+	// the connection, and what ServeConn returned
+	t.Helper()
+	serverEnd, clientEnd := net.Pipe()
+	defer clientEnd.Close()
+	served := make(chan error, 1)
+	go func() { served <- s.ServeConn(serverEnd) }()
+	go clientEnd.Write([]byte(request))
+	clientEnd.SetReadDeadline(time.Now().Add(5 * time.Second))
+	replies, readErr := io.ReadAll(clientEnd)
+	if readErr != nil {
+		t.Fatalf("reading the replies: %v", readErr)
+	}
+	return string(replies), <-served
+}
+
+func newServer() *Server {
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	return New(&indexStructure)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestCommands(t *testing.T) {
+	// every command is sent before any reply is read, and the replies come back in the order of the commands
+	s := newServer()
+	request := "*3\r\n$7\r\nKINSERT\r\n$5\r\napple\r\n$1\r\n1\r\n" +
+		"*3\r\n$7\r\nkinsert\r\n$5\r\napple\r\n$1\r\n1\r\n" +
+		"KINSERT apply 2\r\n" +
+		"KINSERT banana 3\n" +
+		"*3\r\n$7\r\nKSEARCH\r\n$2\r\nap\r\n$6\r\nPREFIX\r\n" +
+		"KSEARCH apple\r\n" +
+		"KSEARCH apple EXACT\r\n" +
+		"KSEARCH apple WHAT\r\n" +
+		"KSEARCH\r\n" +
+		"KDEL apple 1\r\n" +
+		"KDEL apple 1\r\n" +
+		"KINSERT x notnum\r\n" +
+		"*3\r\n$7\r\nKINSERT\r\n$2\r\n  \r\n$1\r\n4\r\n" +
+		"*3\r\n$7\r\nKINSERT\r\n$6\r\na\r\nb c\r\n$1\r\n5\r\n" +
+		"*2\r\n$7\r\nKSEARCH\r\n$6\r\na\r\nb c\r\n" +
+		"PING\r\n" +
+		"PING hi\r\n" +
+		"FOO\r\n" +
+		"\r\n" +
+		"*2\r\n$7\r\nKINSERT\r\n$1\r\nx\r\n" +
+		"COMMAND\r\n" +
+		"QUIT\r\n" +
+		"PING\r\n"
+	want := ":1\r\n" +
+		":0\r\n" +
+		":1\r\n" +
+		":1\r\n" +
+		"*2\r\n:1\r\n:2\r\n" +
+		"*1\r\n:1\r\n" +
+		"*1\r\n:1\r\n" +
+		"-ERR syntax error\r\n" +
+		"-ERR wrong number of arguments for 'ksearch' command\r\n" +
+		":1\r\n" +
+		":0\r\n" +
+		"-ERR value is not an integer or out of range\r\n" +
+		"-ERR blank key, or number too large for the index\r\n" +
+		":1\r\n" +
+		"*1\r\n:5\r\n" +
+		"+PONG\r\n" +
+		"$2\r\nhi\r\n" +
+		"-ERR unknown command 'FOO'\r\n" +
+		"-ERR wrong number of arguments for 'kinsert' command\r\n" +
+		"*0\r\n" +
+		"+OK\r\n"
+	if reply, err := exchange(t, s, request); reply != want || err != nil {
+		t.Errorf("replies were %q %v, want %q", reply, err, want)
+	}
+	//
+	reply, err := exchange(t, s, "KSTATS\r\nQUIT\r\n")
+	if err != nil || !strings.HasPrefix(reply, "$") || !strings.Contains(reply, "\r\nKeys:3\r\n") ||
+		!strings.HasSuffix(reply, "\r\n+OK\r\n") {
+		t.Errorf("KSTATS gave %q %v", reply, err)
+	}
+}
+
+func TestPipelining(t *testing.T) {
+	// the replies to commands sent together are flushed once they have all been answered, without waiting for more
+	s := newServer()
+	serverEnd, clientEnd := net.Pipe()
+	defer clientEnd.Close()
+	go s.ServeConn(serverEnd)
+	r := bufio.NewReader(clientEnd)
+	for x, batch := range []struct{ request, want string }{
+		{request: "KINSERT a 1\r\nKINSERT a 2\r\nKINSERT b 3\r\n", want: ":1\r\n:1\r\n:1\r\n"},
+		{request: "KSEARCH a\r\nKDEL a 1\r\nKSEARCH a\r\n", want: "*2\r\n:1\r\n:2\r\n:1\r\n*1\r\n:2\r\n"},
+		{request: "PING\r\n", want: "+PONG\r\n"},
+	} {
+		go clientEnd.Write([]byte(batch.request))
+		clientEnd.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reply := make([]byte, len(batch.want))
+		if _, err := io.ReadFull(r, reply); err != nil || string(reply) != batch.want {
+			t.Fatalf("batch %d gave %q %v, want %q", x, reply, err, batch.want)
+		}
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	// a request that cannot be read is answered with an error and the connection is dropped, after the replies to
+	// the commands before it
+	long := strings.Repeat("a", maxLine)
+	longest := "$" + strconv.Itoa(maxBulk) + "\r\n" + strings.Repeat("a", maxBulk) + "\r\n"
+	tests := []struct {
+		name, request, want string
+	}{
+		{name: "no bulk string", request: "*1\r\n%3\r\n", want: "-ERR Protocol error: expected '$'\r\n"},
+		{name: "bad count", request: "*x\r\n", want: "-ERR Protocol error: invalid length\r\n"},
+		{name: "too many arguments", request: "*1025\r\n", want: "-ERR Protocol error: invalid length\r\n"},
+		{name: "bulk too long", request: "*1\r\n$1048577\r\n", want: "-ERR Protocol error: invalid length\r\n"},
+		{name: "bulk not ended", request: "*1\r\n$4\r\nPINGxx",
+			want: "-ERR Protocol error: bulk string not ended by CRLF\r\n"},
+		{name: "after commands", request: "PING\r\nKINSERT a 1\r\n*1\r\n+PING\r\n",
+			want: "+PONG\r\n:1\r\n-ERR Protocol error: expected '$'\r\n"},
+		{name: "inline too long", request: "KSEARCH " + long + "\r\n", want: "-ERR Protocol error: line too long\r\n"},
+		{name: "inline without end", request: "PING\r\n" + long,
+			want: "+PONG\r\n-ERR Protocol error: line too long\r\n"},
+		{name: "length too long", request: "*1\r\n$" + long, want: "-ERR Protocol error: line too long\r\n"},
+		{name: "command too long", request: "*5\r\n" + strings.Repeat(longest, maxCommand/maxBulk) + "$1\r\n",
+			want: "-ERR Protocol error: command too long\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reply, err := exchange(t, newServer(), test.request)
+			var bad protocolError
+			if reply != test.want || !errors.As(err, &bad) {
+				t.Errorf("gave %q %v, want %q", reply, err, test.want)
+			}
+		})
+	}
+}
+
+func TestBulkCutShort(t *testing.T) {
+	// a bulk string that claims the longest length but stops after a few bytes takes memory only for what was sent,
+	// and an argument that was sent whole is read as it is
+	for _, test := range []struct {
+		request string
+		want    []string
+		wantErr error
+	}{
+		{request: "*1\r\n$" + strconv.Itoa(maxBulk) + "\r\nKSEARCH", wantErr: io.ErrUnexpectedEOF},
+		{request: "*2\r\n$7\r\nKSEARCH\r\n$0\r\n\r\n", want: []string{"KSEARCH", ""}},
+	} {
+		var before, after runtime.MemStats
+		r := bufio.NewReaderSize(strings.NewReader(test.request), maxLine)
+		runtime.ReadMemStats(&before)
+		args, err := readCommand(r)
+		runtime.ReadMemStats(&after)
+		if strings.Join(args, ",") != strings.Join(test.want, ",") || err != test.wantErr {
+			t.Errorf("readCommand(%q) gave %q %v, want %q %v", test.request, args, err, test.want, test.wantErr)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > maxBulk/16 {
+			t.Errorf("readCommand(%q) allocated %d bytes", test.request, allocated)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := newServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(listener) }()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("KINSERT a 1\r\n"))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != ":1\r\n" {
+		t.Fatalf("KINSERT gave %q %v", reply, err)
+	}
+	s.Update(func(indexStructure *key.Index) {
+		if _, indexes := key.Search("a", true, indexStructure); len(indexes) != 1 {
+			t.Errorf("the index holds %v under a", indexes)
+		}
+	})
+	//
+	s.Close()
+	if err := <-served; err != ErrClosed {
+		t.Errorf("Serve gave %v, want %v", err, ErrClosed)
+	}
+	if rest, err := io.ReadAll(conn); len(rest) > 0 || err != nil {
+		t.Errorf("after Close the client read %q %v", rest, err)
+	}
+}
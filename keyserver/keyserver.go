@@ -0,0 +1,310 @@
+// Package keyserver serves named indexes over HTTP, taking and giving JSON
+//
+//	GET    /indexes                                 names of the indexes
+//	PUT    /indexes/{name}                          makes an empty index, if there is none of that name
+//	DELETE /indexes/{name}                          drops the index
+//	POST   /indexes/{name}/insert                   {"key": "...", "number": n}, answered 201 if it was added or 200 if
+//	                                                it was already there
+//	POST   /indexes/{name}/delete                   {"key": "...", "number": n}, answered 200 if it was removed or 404
+//	                                                if it was not there
+//	GET    /indexes/{name}/search?key=k[&prefix=1]  the "index-numbers" filed under k, or under every key starting k
+//	GET    /indexes/{name}/range?low=l&high=h       the "index-numbers" of the keys from l to h
+//	GET    /indexes/{name}/count?key=k[&prefix=1]   how many "index-numbers" search would give
+//	GET    /indexes/{name}/stats                    the Statistic of the index
+//
+// an insert or delete is answered with {"result": "..."}, naming what was done -- see key.OpResult
+// every failure is answered with a status code and {"error": "..."}
+package keyserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apwoodhouse/key"
+)
+
+// Entry is the body of an insert or delete
+//
+type Entry struct {
+	Key    string `json:"key"`
+	Number int    `json:"number"`
+}
+
+// Result is the answer to an insert or delete
+//
+type Result struct {
+	Result string `json:"result"`
+}
+
+// Numbers is the answer to a search or range
+//
+type Numbers struct {
+	Found   bool  `json:"found"`
+	Numbers []int `json:"numbers"`
+}
+
+// Count is the answer to a count
+//
+type Count struct {
+	Count int `json:"count"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// a hosted index has a lock of its own, so requests to different indexes never wait for each other
+//
+type hosted struct {
+	mu    sync.RWMutex
+	index *key.Index
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Server is an http.Handler serving a set of named indexes
+//
+type Server struct {
+	mu      sync.RWMutex
+	indexes map[string]*hosted
+}
+
+// New makes a Server with no indexes
+//
+func New() *Server {
+	return &Server{indexes: make(map[string]*hosted)}
+}
+
+// Add serves "indexStructure" under "name", in place of any index already of that name -- from then on the index
+// should only be used through the Server
+//
+func (s *Server) Add(name string, indexStructure *key.Index) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes[name] = &hosted{index: indexStructure}
+}
+
+// View calls "read" with the index of the specified name while no request is changing it, and returns false if
+// there is no such index -- it is how to Save a served index
+//
+func (s *Server) View(name string, read func(indexStructure *key.Index)) bool {
+	h := s.lookup(name)
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	read(h.index)
+	return true
+}
+
+// Names returns the names of the indexes served, in order
+//
+func (s *Server) Names() (names []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names = make([]string, 0, len(s.indexes))
+	for name := range s.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (s *Server) lookup(name string) *hosted {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.indexes[name]
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func fail(w http.ResponseWriter, status int, message string) {
+	reply(w, status, errorBody{Error: message})
+}
+
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	fail(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed")
+	return false
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// ServeHTTP answers one request
+//
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "indexes" || len(parts) > 3 || (len(parts) > 1 && parts[1] == "") {
+		fail(w, http.StatusNotFound, "no such resource")
+		return
+	}
+	switch len(parts) {
+	case 1:
+		if allow(w, r, http.MethodGet) {
+			reply(w, http.StatusOK, s.Names())
+		}
+	case 2:
+		s.serveIndex(w, r, parts[1])
+	default:
+		h := s.lookup(parts[1])
+		if h == nil {
+			fail(w, http.StatusNotFound, "no index "+parts[1])
+			return
+		}
+		operation, found := operations[parts[2]]
+		if !found {
+			fail(w, http.StatusNotFound, "no operation "+parts[2])
+			return
+		}
+		operation(h, w, r)
+	}
+}
+
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request, name string) {
+	if !allow(w, r, http.MethodPut, http.MethodDelete) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.indexes[name]
+	switch {
+	case r.Method == http.MethodDelete && !exists:
+		fail(w, http.StatusNotFound, "no index "+name)
+	case r.Method == http.MethodDelete:
+		delete(s.indexes, name)
+		w.WriteHeader(http.StatusNoContent)
+	case exists:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		indexStructure := &key.Index{}
+		key.Initialise(indexStructure)
+		s.indexes[name] = &hosted{index: indexStructure}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+var operations = map[string]func(h *hosted, w http.ResponseWriter, r *http.Request){
+	"insert": change(false),
+	"delete": change(true),
+	"search": search,
+	"range":  searchRange,
+	"count":  count,
+	"stats":  stats,
+}
+
+func change(remove bool) func(h *hosted, w http.ResponseWriter, r *http.Request) {
+	return func(h *hosted, w http.ResponseWriter, r *http.Request) {
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		var entry Entry
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entry); err != nil {
+			fail(w, http.StatusBadRequest, "bad entry: "+err.Error())
+			return
+		}
+		if strings.TrimSpace(entry.Key) == "" {
+			fail(w, http.StatusBadRequest, "blank key")
+			return
+		}
+		h.mu.Lock()
+		result := key.ApplyBatch([]key.Op{{Delete: remove, Key: entry.Key, KeyNumber: entry.Number}}, h.index)[0]
+		h.mu.Unlock()
+		switch result {
+		case key.OpInserted:
+			reply(w, http.StatusCreated, Result{Result: result.String()})
+		case key.OpExists, key.OpDeleted:
+			reply(w, http.StatusOK, Result{Result: result.String()})
+		case key.OpNotFound:
+			fail(w, http.StatusNotFound, "entry not found")
+		default:
+			fail(w, http.StatusBadRequest, "number does not fit the index")
+		}
+	}
+}
+
+func find(h *hosted, r *http.Request) (matchFound bool, indexes []int, err error) {
+	query := r.URL.Query()
+	keyInput := query.Get("key")
+	if strings.TrimSpace(keyInput) == "" {
+		return false, nil, errors.New("no key given")
+	}
+	prefix := query.Get("prefix")
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	matchFound, indexes = key.Search(keyInput, prefix == "" || prefix == "0" || prefix == "false", h.index)
+	return
+}
+
+func search(h *hosted, w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	matchFound, indexes, err := find(h, r)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reply(w, http.StatusOK, Numbers{Found: matchFound, Numbers: append([]int{}, indexes...)})
+}
+
+func count(h *hosted, w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	_, indexes, err := find(h, r)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reply(w, http.StatusOK, Count{Count: len(indexes)})
+}
+
+func searchRange(h *hosted, w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query()
+	h.mu.RLock()
+	matchFound, indexes := key.SearchRange(query.Get("low"), query.Get("high"), h.index)
+	h.mu.RUnlock()
+	reply(w, http.StatusOK, Numbers{Found: matchFound, Numbers: append([]int{}, indexes...)})
+}
+
+func stats(h *hosted, w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	h.mu.RLock()
+	result := key.Statistics(h.index)
+	h.mu.RUnlock()
+	reply(w, http.StatusOK, result)
+}
@@ -0,0 +1,104 @@
+package keyserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apwoodhouse/key"
+)
+
+func TestServer(t *testing.T) {
+	// each request is made in turn to the same Server, and answered with the status and body given -- a body of "*"
+	// is not checked
+	s := New()
+	var compact key.Index
+	key.InitialiseLayout(&compact, key.CompactLayout)
+	key.Insert("q", 9, &compact)
+	s.Add("compact", &compact)
+	server := httptest.NewServer(s)
+	defer server.Close()
+	tests := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"GET", "/indexes", "", http.StatusOK, `["compact"]`},
+		{"PUT", "/indexes/a", "", http.StatusCreated, ""},
+		{"PUT", "/indexes/a", "", http.StatusNoContent, ""},
+		{"PUT", "/indexes/a/insert", "", http.StatusMethodNotAllowed, `{"error":"method PUT not allowed"}`},
+		{"GET", "/indexes", "", http.StatusOK, `["a","compact"]`},
+		//
+		{"POST", "/indexes/a/insert", `{"key":"apple","number":0}`, http.StatusCreated, `{"result":"inserted"}`},
+		{"POST", "/indexes/a/insert", `{"key":"apply","number":1}`, http.StatusCreated, `{"result":"inserted"}`},
+		{"POST", "/indexes/a/insert", `{"key":"apt","number":2}`, http.StatusCreated, `{"result":"inserted"}`},
+		{"POST", "/indexes/a/insert", `{"key":" apple ","number":4}`, http.StatusCreated, `{"result":"inserted"}`},
+		{"POST", "/indexes/a/insert", `{"key":"apple","number":0}`, http.StatusOK, `{"result":"exists"}`},
+		{"POST", "/indexes/a/delete", `{"key":"apple","number":0}`, http.StatusOK, `{"result":"deleted"}`},
+		{"POST", "/indexes/a/delete", `{"key":"apple","number":0}`, http.StatusNotFound,
+			`{"error":"entry not found"}`},
+		{"POST", "/indexes/a/delete", `{"key":"banana","number":0}`, http.StatusNotFound,
+			`{"error":"entry not found"}`},
+		{"POST", "/indexes/compact/insert", `{"key":"q","number":8589934592}`, http.StatusBadRequest,
+			`{"error":"number does not fit the index"}`},
+		//
+		{"POST", "/indexes/a/insert", `{"key":"  ","number":1}`, http.StatusBadRequest, `{"error":"blank key"}`},
+		{"POST", "/indexes/a/insert", `{"key":"x","number":`, http.StatusBadRequest, "*"},
+		{"POST", "/indexes/a/insert", `{"kee":"x"}`, http.StatusBadRequest, "*"},
+		{"POST", "/indexes/a/insert", `{"key":1}`, http.StatusBadRequest, "*"},
+		{"GET", "/indexes/a/insert", "", http.StatusMethodNotAllowed, "*"},
+		{"POST", "/indexes/b/insert", `{"key":"x","number":1}`, http.StatusNotFound, `{"error":"no index b"}`},
+		{"GET", "/indexes/b/search?key=x", "", http.StatusNotFound, `{"error":"no index b"}`},
+		{"GET", "/indexes/a/nothing", "", http.StatusNotFound, `{"error":"no operation nothing"}`},
+		{"GET", "/other", "", http.StatusNotFound, `{"error":"no such resource"}`},
+		//
+		{"GET", "/indexes/a/search?key=apple", "", http.StatusOK, `{"found":true,"numbers":[4]}`},
+		{"GET", "/indexes/a/search?key=zz", "", http.StatusOK, `{"found":false,"numbers":[]}`},
+		{"GET", "/indexes/a/search?key=ap&prefix=1", "", http.StatusOK, `{"found":true,"numbers":[4,1,2]}`},
+		{"GET", "/indexes/a/search", "", http.StatusBadRequest, `{"error":"no key given"}`},
+		{"GET", "/indexes/a/count?key=ap&prefix=true", "", http.StatusOK, `{"count":3}`},
+		{"GET", "/indexes/a/range?low=apply&high=b", "", http.StatusOK, `{"found":true,"numbers":[1,2]}`},
+		{"GET", "/indexes/compact/search?key=q", "", http.StatusOK, `{"found":true,"numbers":[9]}`},
+		{"GET", "/indexes/a/stats", "", http.StatusOK, "*"},
+		//
+		{"DELETE", "/indexes/a", "", http.StatusNoContent, ""},
+		{"DELETE", "/indexes/a", "", http.StatusNotFound, `{"error":"no index a"}`},
+		{"GET", "/indexes", "", http.StatusOK, `["compact"]`},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.TrimSpace(string(body))
+		if response.StatusCode != test.status || (test.want != "*" && got != test.want) {
+			t.Errorf("%s %s %s gave %d %s, want %d %s", test.method, test.path, test.body, response.StatusCode, got,
+				test.status, test.want)
+		}
+	}
+	//
+	var statistics key.Statistic
+	s.View("compact", func(indexStructure *key.Index) { statistics = key.Statistics(indexStructure) })
+	response, err := http.Get(server.URL + "/indexes/compact/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var got key.Statistic
+	if err := json.NewDecoder(response.Body).Decode(&got); err != nil || !reflect.DeepEqual(got, statistics) {
+		t.Errorf("stats gave %+v %v, want %+v", got, err, statistics)
+	}
+}
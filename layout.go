@@ -0,0 +1,154 @@
+package key
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+)
+
+// Layout chooses how an index structure holds its nodes in memory
+//
+type Layout int
+
+const (
+	// WideLayout holds native int pointers -- 24 bytes a node on 64-bit machines
+	WideLayout Layout = iota
+	// CompactLayout holds 32-bit pointers and packs the status and character into one field -- 12 bytes a node, but
+	// index numbers must lie between math.MinInt32 and math.MaxInt32 and Insert ignores any that do not, while
+	// ApplyBatch reports them as OpIgnored and Transaction.Insert returns ErrNumberRange
+	CompactLayout
+)
+
+// ErrNumberRange is returned by Transaction.Insert for an "index-number" that does not fit the compact layout of the
+// index
+//
+var ErrNumberRange = errors.New("key: index-number does not fit the compact layout")
+
+type packedNode struct {
+	leftPointer  int32
+	rightPointer int32
+	statusKey    uint16 // status in the high byte and character in the low byte
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// InitialiseLayout sets up an empty index structure that holds its nodes in the chosen layout -- Initialise gives
+// the wide layout, and every function works the same way on either
+//
+func InitialiseLayout(indexStructure *Index, layout Layout) {
+	*indexStructure = Index{compact: layout == CompactLayout}
+	Initialise(indexStructure)
+}
+
+// IndexLayout returns the layout the specified index structure holds its nodes in
+//
+func IndexLayout(indexStructure *Index) Layout {
+	if indexStructure.compact {
+		return CompactLayout
+	}
+	return WideLayout
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) nodeAt(x int) indexNode {
+	if !indexStructure.compact {
+		return indexStructure.node[x]
+	}
+	p := indexStructure.packed[x]
+	return indexNode{status: byte(p.statusKey >> 8), leftPointer: int(p.leftPointer), key: byte(p.statusKey),
+		rightPointer: int(p.rightPointer)}
+}
+
+func pack(node indexNode) packedNode {
+	return packedNode{leftPointer: int32(node.leftPointer), rightPointer: int32(node.rightPointer),
+		statusKey: uint16(node.status)<<8 | uint16(node.key)}
+}
+
+func (indexStructure *Index) setNode(x int, node indexNode) {
+	if !indexStructure.compact {
+		indexStructure.node[x] = node
+		return
+	}
+	indexStructure.packed[x] = pack(node)
+}
+
+func (indexStructure *Index) appendNode(node indexNode) (x int) {
+	if !indexStructure.compact {
+		indexStructure.node = append(indexStructure.node, node)
+		return len(indexStructure.node) - 1
+	}
+	indexStructure.packed = append(indexStructure.packed, pack(node))
+	return len(indexStructure.packed) - 1
+}
+
+func (indexStructure *Index) truncateNodes(count int) {
+	if !indexStructure.compact {
+		indexStructure.node = indexStructure.node[:count]
+		return
+	}
+	indexStructure.packed = indexStructure.packed[:count]
+}
+
+func (indexStructure *Index) nodeCount() int {
+	if !indexStructure.compact {
+		return len(indexStructure.node)
+	}
+	return len(indexStructure.packed)
+}
+
+func (indexStructure *Index) nodeSize() int {
+	if !indexStructure.compact {
+		return int(unsafe.Sizeof(indexNode{}))
+	}
+	return int(unsafe.Sizeof(packedNode{}))
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) setStatus(x int, status byte) {
+	if !indexStructure.compact {
+		indexStructure.node[x].status = status
+		return
+	}
+	indexStructure.packed[x].statusKey = uint16(status)<<8 | indexStructure.packed[x].statusKey&0xff
+}
+
+func (indexStructure *Index) setKey(x int, key byte) {
+	if !indexStructure.compact {
+		indexStructure.node[x].key = key
+		return
+	}
+	indexStructure.packed[x].statusKey = indexStructure.packed[x].statusKey&0xff00 | uint16(key)
+}
+
+func (indexStructure *Index) setLeftPointer(x, leftPointer int) {
+	if !indexStructure.compact {
+		indexStructure.node[x].leftPointer = leftPointer
+		return
+	}
+	indexStructure.packed[x].leftPointer = int32(leftPointer)
+}
+
+func (indexStructure *Index) setRightPointer(x, rightPointer int) {
+	if !indexStructure.compact {
+		indexStructure.node[x].rightPointer = rightPointer
+		return
+	}
+	indexStructure.packed[x].rightPointer = int32(rightPointer)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) holdsNumber(keyNumber int) bool {
+	// whether an "index-number" fits in a node's left pointer
+	return !indexStructure.compact || (keyNumber >= math.MinInt32 && keyNumber <= math.MaxInt32)
+}
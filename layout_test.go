@@ -0,0 +1,146 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var benchmarkLayouts = []struct {
+	name   string
+	layout Layout
+}{
+	{name: "wide", layout: WideLayout},
+	{name: "compact", layout: CompactLayout},
+}
+
+func benchmarkKeys(count int) (keys []string) {
+	r := rand.New(rand.NewSource(1))
+	keys = make([]string, count)
+	for x := range keys {
+		keys[x] = fmt.Sprintf("user/%08x/%04d", r.Uint32(), r.Intn(10000))
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestLayouts(t *testing.T) {
+	// the same keys give the same entries and node structure in either layout, and the compact one takes less room
+	keys := benchmarkKeys(2000)
+	var indexes []*Index
+	for _, layout := range benchmarkLayouts {
+		indexStructure := &Index{}
+		InitialiseLayout(indexStructure, layout.layout)
+		if IndexLayout(indexStructure) != layout.layout {
+			t.Fatalf("IndexLayout gave %v, want %v", IndexLayout(indexStructure), layout.layout)
+		}
+		m := testModel{}
+		for x, keyInput := range keys {
+			Insert(keyInput, x, indexStructure)
+			m.insert(keyInput, x)
+		}
+		checkIndex(t, indexStructure, m)
+		indexes = append(indexes, indexStructure)
+	}
+	if nodesText(t, indexes[0]) != nodesText(t, indexes[1]) {
+		t.Errorf("the layouts hold different nodes")
+	}
+	wide, compact := Statistics(indexes[0]).BytesInUse, Statistics(indexes[1]).BytesInUse
+	if compact >= wide {
+		t.Errorf("compact layout uses %d bytes, wide %d", compact, wide)
+	}
+}
+
+func TestCompactNumberRange(t *testing.T) {
+	// a number beyond 32 bits is ignored by Insert in the compact layout, reported as OpIgnored by ApplyBatch and
+	// refused with ErrNumberRange by Transaction.Insert, while the wide layout files it
+	tests := []struct {
+		keyNumber int
+		fits      bool
+	}{
+		{math.MaxInt32, true}, {math.MinInt32, true}, {0, true},
+		{math.MaxInt32 + 1, false}, {math.MinInt32 - 1, false}, {1 << 40, false}, {math.MinInt64, false},
+	}
+	for _, layout := range benchmarkLayouts {
+		for _, test := range tests {
+			fits := test.fits || layout.layout == WideLayout
+			name := fmt.Sprint(layout.name, "/", test.keyNumber)
+			var indexStructure Index
+			InitialiseLayout(&indexStructure, layout.layout)
+			Insert("a", test.keyNumber, &indexStructure)
+			if matchFound, indexes := Search("a", true, &indexStructure); matchFound != fits {
+				t.Errorf("%s: Insert filed %v", name, indexes)
+			}
+			//
+			want := OpIgnored
+			if fits {
+				want = OpInserted
+			}
+			if results := ApplyBatch([]Op{{Key: "b", KeyNumber: test.keyNumber}}, &indexStructure); results[0] != want {
+				t.Errorf("%s: ApplyBatch gave %v, want %v", name, results[0], want)
+			}
+			//
+			transaction, _ := Begin(&indexStructure)
+			err := transaction.Insert("c", test.keyNumber)
+			if (err == nil) != fits || (err != nil && !errors.Is(err, ErrNumberRange)) {
+				t.Errorf("%s: Transaction.Insert gave %v", name, err)
+			}
+			transaction.Commit()
+			if matchFound, _ := Search("c", true, &indexStructure); matchFound != fits {
+				t.Errorf("%s: the transaction filed %v", name, matchFound)
+			}
+			if valid, faults := Verify(&indexStructure); !valid {
+				t.Fatalf("%s: Verify: %v", name, faults)
+			}
+		}
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func BenchmarkSearch(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	for _, layout := range benchmarkLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			var indexStructure Index
+			InitialiseLayout(&indexStructure, layout.layout)
+			for x, keyInput := range keys {
+				Insert(keyInput, x, &indexStructure)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for x := 0; x < b.N; x++ {
+				Search(keys[x%len(keys)], true, &indexStructure)
+			}
+			b.ReportMetric(float64(Statistics(&indexStructure).BytesInUse)/float64(len(keys)), "bytes/key")
+		})
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	for _, layout := range benchmarkLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			var indexStructure Index
+			InitialiseLayout(&indexStructure, layout.layout)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for x := 0; x < b.N; x++ {
+				if x%len(keys) == 0 && x > 0 { // start again rather than only finding the keys already there
+					b.StopTimer()
+					InitialiseLayout(&indexStructure, layout.layout)
+					b.StartTimer()
+				}
+				Insert(keys[x%len(keys)], x, &indexStructure)
+			}
+			b.ReportMetric(float64(Statistics(&indexStructure).BytesInUse)/float64(min(b.N, len(keys))), "bytes/key")
+		})
+	}
+}
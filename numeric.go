@@ -0,0 +1,120 @@
+package key
+
+import (
+	"time"
+)
+
+// integer and time keys are a marker byte followed by the value in a fixed number of digits of six bits each, the
+// sign bit flipped so that negative values sort first -- each digit is a byte from 0x80 to 0xbf, which can only ever
+// follow another byte in UTF-8, so no byte of the key is ever white space, alone or with its neighbours, and the key
+// survives trimming without a closing byte, while the marker keeps integers, times and ordinary keys apart
+//
+const (
+	intMarker  = 0x01
+	timeMarker = 0x02
+	digitBits  = 6
+	digitBase  = 0x80
+)
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func appendDigits(b []byte, value uint64, bits int) []byte {
+	// the low "bits" of the value, most significant digit first
+	for shift := (bits - 1) / digitBits * digitBits; shift >= 0; shift -= digitBits {
+		b = append(b, byte(digitBase|(value>>shift)&(1<<digitBits-1)))
+	}
+	return b
+}
+
+// IntKey returns the key InsertInt files an integer under -- integer keys sort in numeric order
+//
+func IntKey(value int64) string {
+	b := make([]byte, 0, 12)
+	b = append(b, intMarker)
+	b = appendDigits(b, uint64(value)^1<<63, 64)
+	return string(b)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// TimeKey returns the key InsertTime files a time under -- time keys sort in time order to the nanosecond and the
+// time's location plays no part
+//
+func TimeKey(value time.Time) string {
+	b := make([]byte, 0, 17)
+	b = append(b, timeMarker)
+	b = appendDigits(b, uint64(value.Unix())^1<<63, 64)
+	b = appendDigits(b, uint64(value.Nanosecond()), 30)
+	return string(b)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// InsertInt adds an integer key and its index number to the specified index structure
+//
+func InsertInt(value int64, keyNumber int, indexStructure *Index) {
+	Insert(IntKey(value), keyNumber, indexStructure)
+}
+
+// DeleteInt removes an integer key and index number pair from the specified index structure
+//
+func DeleteInt(value int64, keyNumber int, indexStructure *Index) {
+	Delete(IntKey(value), keyNumber, indexStructure)
+}
+
+// SearchInt returns the index numbers filed under an integer key
+// "matchFound" is "true" if something is located
+//
+func SearchInt(value int64, indexStructure *Index) (matchFound bool, indexes []int) {
+	return Search(IntKey(value), true, indexStructure)
+}
+
+// SearchIntRange returns the index numbers of every integer key from "low" to "high" inclusive, in ascending numeric
+// order
+// "matchFound" is "true" if something is located
+//
+func SearchIntRange(low, high int64, indexStructure *Index) (matchFound bool, indexes []int) {
+	if low > high {
+		return
+	}
+	return SearchRange(IntKey(low), IntKey(high), indexStructure)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// InsertTime adds a time key and its index number to the specified index structure
+//
+func InsertTime(value time.Time, keyNumber int, indexStructure *Index) {
+	Insert(TimeKey(value), keyNumber, indexStructure)
+}
+
+// DeleteTime removes a time key and index number pair from the specified index structure
+//
+func DeleteTime(value time.Time, keyNumber int, indexStructure *Index) {
+	Delete(TimeKey(value), keyNumber, indexStructure)
+}
+
+// SearchTime returns the index numbers filed under a time key
+// "matchFound" is "true" if something is located
+//
+func SearchTime(value time.Time, indexStructure *Index) (matchFound bool, indexes []int) {
+	return Search(TimeKey(value), true, indexStructure)
+}
+
+// SearchTimeRange returns the index numbers of every time key from "low" to "high" inclusive, in ascending time order
+// "matchFound" is "true" if something is located
+//
+func SearchTimeRange(low, high time.Time, indexStructure *Index) (matchFound bool, indexes []int) {
+	if low.After(high) {
+		return
+	}
+	return SearchRange(TimeKey(low), TimeKey(high), indexStructure)
+}
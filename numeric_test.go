@@ -0,0 +1,158 @@
+package key
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func byDigits(indexes []int) []int {
+	// the numbers filed under one key in the order Search gives them
+	sorted := slices.Clone(indexes)
+	sort.Slice(sorted, func(i, j int) bool { return strconv.Itoa(sorted[i]) < strconv.Itoa(sorted[j]) })
+	return sorted
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestIntKeys(t *testing.T) {
+	// integers are kept whatever their bytes, found by value and range in numeric order, and deleted
+	values := []int64{0, 1, -1, 9, 10, 13, 32, -32, 0x20 << 6, 133, 160, 0x2028, 255, 256, -256, 1 << 40, -(1 << 40),
+		math.MaxInt64, math.MinInt64, math.MaxInt64 - 1, math.MinInt64 + 1}
+	r := rand.New(rand.NewSource(1))
+	for x := 0; x < 200; x++ {
+		values = append(values, r.Int63n(2000)-1000, int64(r.Uint64()))
+	}
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			indexStructure := c.index()
+			Insert("plain", 0, indexStructure)
+			held := map[int64][]int{}
+			for x, value := range values {
+				InsertInt(value, x, indexStructure)
+				held[value] = append(held[value], x)
+			}
+			// the values in numeric order, and the numbers filed under each in the order of their digits
+			inRange := func(low, high int64) (indexes []int) {
+				var found []int64
+				for value := range held {
+					if value >= low && value <= high {
+						found = append(found, value)
+					}
+				}
+				sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+				for _, value := range found {
+					indexes = append(indexes, byDigits(held[value])...)
+				}
+				return
+			}
+			for x, value := range values {
+				if matchFound, got := SearchInt(value, indexStructure); !matchFound || !slices.Contains(got, x) {
+					t.Fatalf("SearchInt(%d) gave %v %v, want %d among them", value, matchFound, got, x)
+				}
+			}
+			bounds := [][2]int64{{-10, 10}, {-1, -1}, {1, 0}, {math.MinInt64, -1}, {0, math.MaxInt64},
+				{math.MinInt64, math.MaxInt64}, {5, 300}, {-1 << 41, 1 << 41}, {math.MaxInt64, math.MaxInt64},
+				{math.MinInt64, math.MinInt64}, {2, 8}}
+			for _, bound := range bounds {
+				want := inRange(bound[0], bound[1])
+				matchFound, got := SearchIntRange(bound[0], bound[1], indexStructure)
+				if matchFound != (len(want) > 0) || fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("SearchIntRange(%d, %d) gave %v %v, want %v", bound[0], bound[1], matchFound, got, want)
+				}
+			}
+			if _, got := Search("plain", true, indexStructure); fmt.Sprint(got) != "[0]" {
+				t.Errorf("Search(plain) gave %v", got)
+			}
+			//
+			for x, value := range values {
+				DeleteInt(value, x, indexStructure)
+			}
+			if matchFound, got := SearchIntRange(math.MinInt64, math.MaxInt64, indexStructure); matchFound {
+				t.Errorf("SearchIntRange after every DeleteInt gave %v", got)
+			}
+			if valid, faults := Verify(indexStructure); !valid {
+				t.Fatalf("Verify: %v", faults)
+			}
+		})
+	}
+}
+
+func TestTimeKeys(t *testing.T) {
+	// times are kept to the nanosecond whatever their location, found by range in time order, and deleted
+	base := time.Date(2020, 1, 1, 0, 0, 32, 0, time.UTC)
+	times := []time.Time{base, base.Add(1), base.Add(-1), base.Add(time.Second), base.Add(-time.Second),
+		time.Unix(0, 0), time.Unix(-1, 999999999), time.Unix(32, 32), time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC), base.Add(time.Hour).In(time.FixedZone("x", -7200))}
+	r := rand.New(rand.NewSource(1))
+	for x := 0; x < 200; x++ {
+		times = append(times, base.Add(time.Duration(r.Int63n(int64(200*time.Hour))-int64(100*time.Hour))))
+	}
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			indexStructure := c.index()
+			InsertInt(base.Unix(), 1000, indexStructure)
+			for x, value := range times {
+				InsertTime(value, x, indexStructure)
+			}
+			for x, value := range times {
+				matchFound, got := SearchTime(value.In(time.Local), indexStructure)
+				if !matchFound || !slices.Contains(got, x) {
+					t.Fatalf("SearchTime(%v) gave %v %v, want %d among them", value, matchFound, got, x)
+				}
+			}
+			bounds := [][2]time.Time{{base.Add(-time.Hour), base.Add(time.Hour)}, {base, base}, {base.Add(1), base},
+				{time.Unix(-1, 0), time.Unix(1, 0)}, {times[8], times[9]}, {base.Add(-1), base.Add(1)}}
+			for _, bound := range bounds {
+				var found []int
+				for x, value := range times {
+					if !value.Before(bound[0]) && !value.After(bound[1]) {
+						found = append(found, x)
+					}
+				}
+				sort.SliceStable(found, func(i, j int) bool { return times[found[i]].Before(times[found[j]]) })
+				var want []int
+				for x := 0; x < len(found); {
+					y := x
+					for y < len(found) && times[found[y]].Equal(times[found[x]]) {
+						y++
+					}
+					want = append(want, byDigits(found[x:y])...)
+					x = y
+				}
+				matchFound, got := SearchTimeRange(bound[0], bound[1], indexStructure)
+				if matchFound != (len(want) > 0) || fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("SearchTimeRange(%v, %v) gave %v %v, want %v", bound[0], bound[1], matchFound, got, want)
+				}
+			}
+			//
+			for x, value := range times {
+				DeleteTime(value, x, indexStructure)
+			}
+			if matchFound, got := SearchTimeRange(times[8], times[9], indexStructure); matchFound {
+				t.Errorf("SearchTimeRange after every DeleteTime gave %v", got)
+			}
+			if _, got := SearchInt(base.Unix(), indexStructure); fmt.Sprint(got) != "[1000]" {
+				t.Errorf("SearchInt gave %v after the times were deleted", got)
+			}
+		})
+	}
+}
+
+func TestNumericKeysNotTrimmed(t *testing.T) {
+	// no byte of an integer or time key is white space, so the key is never cut short
+	for _, keyField := range []string{IntKey(32), IntKey(-32), IntKey(0x2028), IntKey(133), IntKey(math.MaxInt64),
+		TimeKey(time.Unix(32, 0x85)), TimeKey(time.Unix(0, 0))} {
+		if strings.TrimSpace(keyField) != keyField || len(keyField) > MaxKeyLength {
+			t.Errorf("key %q is changed by trimming or is too long", keyField)
+		}
+	}
+}
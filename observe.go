@@ -0,0 +1,101 @@
+package key
+
+import (
+	"strconv"
+)
+
+// EventKind says how a change told to an observer altered the index
+//
+type EventKind int
+
+const (
+	KeyInserted      EventKind = iota // a key that was not in the index was added with its first "index-number"
+	DuplicateAdded                    // another "index-number" was added to a key already in the index
+	DuplicateRemoved                  // one of the "index-numbers" of a key was removed and the key is still there
+	KeyDeleted                        // the last "index-number" of a key was removed, taking the key with it
+)
+
+// Event is one change to an index, as given to its observers
+//
+type Event struct {
+	Kind      EventKind
+	Key       string // as it is held in the index -- trimmed and cut to MaxKeyLength
+	KeyNumber int
+}
+
+type observer struct {
+	notify func(Event)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Observe registers "notify" to be called, in the order the observers were registered, for every Insert, Delete or
+// ApplyBatch that changes the specified index -- calls that leave it as it was are not told to anyone
+// changes made through a Transaction are told when it is committed and never if it is rolled back, and Load and
+// Recover, which replace the whole index, are not told at all
+// the returned function stops the calls
+//
+func Observe(notify func(Event), indexStructure *Index) (stop func()) {
+	o := &observer{notify: notify}
+	indexStructure.observers = append(indexStructure.observers, o)
+	return func() {
+		for x, registered := range indexStructure.observers {
+			if registered == o {
+				indexStructure.observers = append(indexStructure.observers[:x:x], indexStructure.observers[x+1:]...)
+				return
+			}
+		}
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) entryState(keyField string, keyNumber int) (keyFound, present bool) {
+	// whether any key "keyField" is in the index, and whether it is there with "keyNumber"
+	found, keyPointer, _ := locate(keyField, indexStructure)
+	if !found {
+		return
+	}
+	switch indexStructure.nodeAt(keyPointer).status {
+	case 'R', 'S', 'K', 'L':
+		return true, indexStructure.holdsEntry(keyPointer, keyNumber)
+	}
+	return
+}
+
+func (indexStructure *Index) notify(event Event) {
+	for _, o := range indexStructure.observers {
+		o.notify(event)
+	}
+}
+
+func (indexStructure *Index) notifyDelete(keyField string, keyNumber int) {
+	// called after the entry has gone, to tell whether it took the key with it
+	event := Event{Kind: DuplicateRemoved, Key: keyField, KeyNumber: keyNumber}
+	if keyFound, _ := indexStructure.entryState(keyField, keyNumber); !keyFound {
+		event.Kind = KeyDeleted
+	}
+	indexStructure.notify(event)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (k EventKind) String() string {
+	switch k {
+	case KeyInserted:
+		return "key inserted"
+	case DuplicateAdded:
+		return "duplicate added"
+	case DuplicateRemoved:
+		return "duplicate removed"
+	case KeyDeleted:
+		return "key deleted"
+	}
+	return "EventKind(" + strconv.Itoa(int(k)) + ")"
+}
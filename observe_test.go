@@ -0,0 +1,72 @@
+package key
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestObserve(t *testing.T) {
+	for _, layout := range benchmarkLayouts {
+		var indexStructure Index
+		InitialiseLayout(&indexStructure, layout.layout)
+		var told []string
+		stopFirst := Observe(func(event Event) { told = append(told, fmt.Sprint("first ", event)) }, &indexStructure)
+		stopSecond := Observe(func(event Event) { told = append(told, fmt.Sprint("second ", event)) }, &indexStructure)
+		check := func(step string, want ...Event) {
+			// each observer is told of each change, in the order they were registered, and no one of anything else
+			t.Helper()
+			var wantTold []string
+			for _, event := range want {
+				wantTold = append(wantTold, fmt.Sprint("first ", event), fmt.Sprint("second ", event))
+			}
+			if fmt.Sprint(told) != fmt.Sprint(wantTold) {
+				t.Errorf("%s: %s told\n%v\nwant\n%v", layout.name, step, told, wantTold)
+			}
+			told = nil
+		}
+		// each kind of change, and the calls that change nothing
+		Insert(" ab ", 1, &indexStructure)
+		Insert("ab", 1, &indexStructure)
+		Insert("ab", 2, &indexStructure)
+		Insert("  ", 3, &indexStructure)
+		check("Insert", Event{KeyInserted, "ab", 1}, Event{DuplicateAdded, "ab", 2})
+		Delete("ab", 1, &indexStructure)
+		Delete("ab", 1, &indexStructure)
+		Delete("a", 2, &indexStructure)
+		Delete("abc", 2, &indexStructure)
+		Delete("ab", 2, &indexStructure)
+		check("Delete", Event{DuplicateRemoved, "ab", 1}, Event{KeyDeleted, "ab", 2})
+		ApplyBatch([]Op{{Key: "b", KeyNumber: 4}, {Key: "b", KeyNumber: 4}, {Delete: true, Key: "b", KeyNumber: 5},
+			{Key: "a", KeyNumber: 5}}, &indexStructure)
+		check("ApplyBatch", Event{KeyInserted, "a", 5}, Event{KeyInserted, "b", 4})
+		// a stopped observer is told nothing more, and stopping it again does nothing
+		stopFirst()
+		stopFirst()
+		Insert("c", 6, &indexStructure)
+		if fmt.Sprint(told) != fmt.Sprint([]string{fmt.Sprint("second ", Event{KeyInserted, "c", 6})}) {
+			t.Errorf("%s: with the first stopped Insert told %v", layout.name, told)
+		}
+		told = nil
+		// and once no one is left to tell, the index goes on changing as it did
+		stopSecond()
+		Insert("c", 6, &indexStructure)
+		Insert("d", 7, &indexStructure)
+		Delete("b", 4, &indexStructure)
+		Delete("b", 4, &indexStructure)
+		check("after stop")
+		for keyInput, want := range map[string][]int{"a": {5}, "b": nil, "c": {6}, "d": {7}} {
+			if _, keyNumbers := Search(keyInput, true, &indexStructure); fmt.Sprint(keyNumbers) != fmt.Sprint(want) {
+				t.Errorf("%s: Search(%q) gave %v, want %v", layout.name, keyInput, keyNumbers, want)
+			}
+		}
+	}
+}
+
+func TestEventKindString(t *testing.T) {
+	for kind, want := range map[EventKind]string{KeyInserted: "key inserted", DuplicateAdded: "duplicate added",
+		DuplicateRemoved: "duplicate removed", KeyDeleted: "key deleted", 9: "EventKind(9)"} {
+		if kind.String() != want {
+			t.Errorf("EventKind %d gave %q, want %q", int(kind), kind.String(), want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package key
+
+import (
+	"strings"
+)
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+type patternElement struct {
+	allowed [256]bool
+	repeat  bool // '*' -- any number of allowed characters, including none
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func compilePattern(pattern string) (elements []patternElement) {
+	for i := 0; i < len(pattern); i++ {
+		var element patternElement
+		switch pattern[i] {
+		//
+		case '?', '*':
+			for c := range element.allowed {
+				element.allowed[c] = true
+			}
+			element.repeat = pattern[i] == '*'
+		//
+		case '[':
+			end := i + 1
+			if end < len(pattern) && (pattern[end] == '!' || pattern[end] == '^') {
+				end++
+			}
+			if end < len(pattern) && pattern[end] == ']' { // a leading ']' is part of the class
+				end++
+			}
+			for end < len(pattern) && pattern[end] != ']' {
+				end++
+			}
+			if end == len(pattern) { // never closed so just an ordinary character
+				element.allowed['['] = true
+				break
+			}
+			class := pattern[i+1 : end]
+			negate := class[0] == '!' || class[0] == '^'
+			if negate {
+				class = class[1:]
+			}
+			for j := 0; j < len(class); j++ {
+				if j+2 < len(class) && class[j+1] == '-' {
+					for c := int(class[j]); c <= int(class[j+2]); c++ {
+						element.allowed[c] = true
+					}
+					j += 2
+				} else {
+					element.allowed[class[j]] = true
+				}
+			}
+			if negate {
+				for c := range element.allowed {
+					element.allowed[c] = !element.allowed[c]
+				}
+			}
+			i = end
+		//
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			element.allowed[pattern[i]] = true
+		//
+		default:
+			element.allowed[pattern[i]] = true
+		}
+		elements = append(elements, element)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func stepPattern(elements []patternElement, from []bool, character byte, to []bool) (alive bool) {
+	// moves every live position in the pattern over "character" -- a position beyond a '*' is live whenever the '*'
+	// is, so that the '*' can match nothing
+	for i := range to {
+		to[i] = false
+	}
+	for i, live := range from[:len(elements)] {
+		if !live || !elements[i].allowed[character] {
+			continue
+		}
+		if elements[i].repeat {
+			to[i] = true
+		} else {
+			to[i+1] = true
+		}
+	}
+	for i := range elements {
+		if to[i] && elements[i].repeat {
+			to[i+1] = true
+		}
+	}
+	for _, live := range to {
+		alive = alive || live
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchPattern returns the index numbers of every key matching a wildcard pattern, in ascending key order
+// '?' matches any one character, '*' matches any run of characters including none, and '[...]' matches one
+// character from a class such as "[0-9]" or "[A-Z_]" -- "[!...]" or "[^...]" matches one character not in the class
+// a '\' makes the next character an ordinary one
+// the characters before the first wildcard are looked up directly and the rest of the tree is only entered where
+// the pattern still allows a match
+// "matchFound" is "true" if something is located
+//
+func SearchPattern(pattern string, indexStructure *Index) (matchFound bool, indexes []int) {
+	elements := compilePattern(strings.TrimSpace(pattern))
+	if indexStructure.indexRoot == nullIndexPointer || len(elements) == 0 {
+		return
+	}
+	//
+	// rows[d] holds the live positions in the pattern after the first d characters of the key
+	rows := make([][]bool, 1, maxKeyLength+1)
+	rows[0] = make([]bool, len(elements)+1)
+	rows[0][0] = true
+	for i := 0; i < len(elements) && elements[i].repeat; i++ {
+		rows[0][i+1] = true
+	}
+	//
+	// a leading run of single ordinary characters is looked up without walking the tree
+	var prefix []byte
+	for _, element := range elements {
+		character, single := singleCharacter(&element)
+		if !single {
+			break
+		}
+		prefix = append(prefix, character)
+	}
+	keyPointer := indexStructure.indexRoot
+	if len(prefix) > 0 {
+		found, foundPointer, keyPath := locate(string(prefix), indexStructure)
+		if !found {
+			return
+		}
+		keyPointer, prefix = foundPointer, []byte(keyPath)
+		for _, character := range prefix {
+			row := make([]bool, len(elements)+1)
+			stepPattern(elements, rows[len(rows)-1], character, row)
+			rows = append(rows, row)
+		}
+	}
+	//
+	walk(keyPointer, prefix, indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			depth := len(keyPath)
+			if len(rows) <= depth {
+				rows = append(rows, make([]bool, len(elements)+1))
+			}
+			descend = stepPattern(elements, rows[depth-1], keyPath[depth-1], rows[depth])
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			if rows[len(keyPath)][len(elements)] {
+				indexes = append(indexes, keyNumbers...)
+			}
+		})
+	matchFound = len(indexes) > 0
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func singleCharacter(element *patternElement) (character byte, single bool) {
+	if element.repeat {
+		return
+	}
+	for c, allowed := range element.allowed {
+		if allowed {
+			if single {
+				single = false
+				return
+			}
+			character, single = byte(c), true
+		}
+	}
+	return
+}
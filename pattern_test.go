@@ -0,0 +1,89 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"strings"
+	"testing"
+)
+
+func patternIndex(keys ...string) (indexStructure *Index, m testModel) {
+	indexStructure, m = &Index{}, testModel{}
+	Initialise(indexStructure)
+	for x, keyInput := range keys {
+		Insert(keyInput, x, indexStructure)
+		m.insert(keyInput, x)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestSearchPattern(t *testing.T) {
+	// well formed patterns over keys of single-byte characters match as path.Match does, once "[!" is written "[^"
+	keys := []string{"a", "ab", "abc", "b*", "*", "?", "a?x", "]", "-", "a-", "\\", "a\\"}
+	r := rand.New(rand.NewSource(1))
+	for x := 0; x < 400; x++ {
+		keys = append(keys, randomKey(r, "abc1*?[]-", 5))
+	}
+	indexStructure, m := patternIndex(keys...)
+	patterns := []string{"a", "a*", "*b", "a?c", "*a*b*", "a**b", "*", "?", "??", "[ab]*", "[^ab]?", "[!a-b]*",
+		"[a-c1]", "[*?]*", "\\*", "a\\?*", "\\[*", "*\\]", "a\\\\", "c*1", " a* ", "zz*"}
+	for _, pattern := range patterns {
+		model := strings.ReplaceAll(strings.TrimSpace(pattern), "[!", "[^")
+		want := m.numbers(func(keyField string) bool {
+			matched, err := path.Match(model, keyField)
+			if err != nil {
+				t.Fatalf("%q: %v", pattern, err)
+			}
+			return matched
+		})
+		if matchFound, got := SearchPattern(pattern, indexStructure); matchFound != (len(want) > 0) ||
+			fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("SearchPattern(%q) gave %v %v, want %v", pattern, matchFound, got, want)
+		}
+	}
+}
+
+func TestSearchPatternEdges(t *testing.T) {
+	// malformed classes, escapes at the end and characters of more than one byte
+	indexStructure, m := patternIndex("a", "b", "[ab", "a[", "]", "-", "a-", "ax", "\\", "a\\", "é", "ée", "e", "1")
+	tests := []struct {
+		name, pattern string
+		want          []string
+	}{
+		{name: "never closed", pattern: "[ab", want: []string{"[ab"}},
+		{name: "open at the end", pattern: "a[", want: []string{"a["}},
+		{name: "leading ]", pattern: "[]x]", want: []string{"]"}},
+		{name: "negated leading ]", pattern: "[!]a-z\\-]", want: []string{"1"}},
+		{name: "trailing -", pattern: "a[x-]", want: []string{"a-", "ax"}},
+		{name: "escaped backslash", pattern: "a\\\\", want: []string{"a\\"}},
+		{name: "backslash at the end", pattern: "a\\", want: []string{"a\\"}},
+		{name: "one byte", pattern: "?", want: []string{"a", "b", "]", "-", "\\", "e", "1"}},
+		{name: "two bytes", pattern: "??", want: []string{"a[", "a-", "ax", "a\\", "é"}},
+		{name: "two bytes and more", pattern: "??e", want: []string{"ée"}},
+		{name: "class of a byte", pattern: "[\xc3]?", want: []string{"é"}},
+		{name: "blank", pattern: "  ", want: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wanted := map[string]bool{}
+			for _, keyField := range test.want {
+				wanted[keyField] = true
+			}
+			want := m.numbers(func(keyField string) bool { return wanted[keyField] })
+			if matchFound, got := SearchPattern(test.pattern, indexStructure); matchFound != (len(want) > 0) ||
+				fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("SearchPattern(%q) gave %v %v, want %v", test.pattern, matchFound, got, want)
+			}
+		})
+	}
+	var empty Index
+	Initialise(&empty)
+	if matchFound, got := SearchPattern("*", &empty); matchFound || got != nil {
+		t.Errorf("SearchPattern on an empty index gave %v %v", matchFound, got)
+	}
+}
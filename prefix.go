@@ -0,0 +1,60 @@
+package key
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// LongestPrefix finds the longest key in the index that is a prefix of the input string -- for example "4412345"
+// matches "441" rather than "44" -- and returns it along with its index numbers
+// "matchFound" is "true" if any key is a prefix of the input
+//
+func LongestPrefix(keyInput string, indexStructure *Index) (matchFound bool, keyPrefix string, indexes []int) {
+	keyField := trimKey(keyInput)
+	keyPointer := indexStructure.indexRoot
+	lastMatchPointer := nullIndexPointer
+	i := 0
+	for keyPointer != nullIndexPointer && i < len(keyField) {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			if keyField[i] <= node.key {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		if node.status == 'P' {
+			run := indexStructure.runs[node.leftPointer]
+			if len(keyField)-i < len(run) || keyField[i:i+len(run)] != run {
+				break
+			}
+			i += len(run)
+			keyPointer = node.rightPointer
+			continue
+		}
+		if keyField[i] != node.key {
+			break
+		}
+		i++
+		if node.status != 'X' { // a key ends here
+			lastMatchPointer = keyPointer
+			keyPrefix = keyField[:i]
+		}
+		if node.status == 'S' || node.status == 'L' { // at the terminal leaf
+			break
+		}
+		keyPointer = node.rightPointer
+	}
+	//
+	if lastMatchPointer == nullIndexPointer {
+		return
+	}
+	matchFound = true
+	node := indexStructure.nodeAt(lastMatchPointer)
+	if node.status == 'K' || node.status == 'L' {
+		indexes = duplicateNumbers(node.leftPointer, indexStructure, nil)
+	} else {
+		indexes = []int{node.leftPointer}
+	}
+	return
+}
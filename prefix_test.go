@@ -0,0 +1,91 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLongestPrefix(t *testing.T) {
+	// "abcdefgh" and "wxyz/abcdefgh" are held partly in runs, "ab" and "dup" have duplicates, "ab" below a longer key
+	// on a 'K' node and "dup" at the end of its branch on an 'L' node
+	keys := []struct {
+		key       string
+		keyNumber int
+	}{
+		{"a", 1}, {"ab", 2}, {"ab", 20}, {"ab", 3}, {"abcdefgh", 4}, {"dup", 5}, {"dup", 6}, {"wxyz/abcdefgh", 7},
+	}
+	tests := []struct {
+		name, keyInput string
+		wantFound      bool
+		wantPrefix     string
+		want           []int
+	}{
+		{name: "blank", keyInput: "  "},
+		{name: "empty", keyInput: ""},
+		{name: "no key is a prefix", keyInput: "b"},
+		{name: "first character only", keyInput: "w"},
+		{name: "inside a run, nothing before it", keyInput: "wxyz/abc"},
+		{name: "exact", keyInput: "abcdefgh", wantFound: true, wantPrefix: "abcdefgh", want: []int{4}},
+		{name: "exact trimmed", keyInput: " a ", wantFound: true, wantPrefix: "a", want: []int{1}},
+		{name: "longer than the key", keyInput: "abcdefghij", wantFound: true, wantPrefix: "abcdefgh", want: []int{4}},
+		{name: "duplicate on a K node", keyInput: "abx", wantFound: true, wantPrefix: "ab", want: []int{2, 20, 3}},
+		{name: "duplicate exact", keyInput: "ab", wantFound: true, wantPrefix: "ab", want: []int{2, 20, 3}},
+		{name: "duplicate on an L node", keyInput: "dupe", wantFound: true, wantPrefix: "dup", want: []int{5, 6}},
+		{name: "ends inside a run", keyInput: "abcdeX", wantFound: true, wantPrefix: "ab", want: []int{2, 20, 3}},
+		{name: "run too short", keyInput: "abcde", wantFound: true, wantPrefix: "ab", want: []int{2, 20, 3}},
+	}
+	for _, c := range testConfigs {
+		indexStructure := c.index()
+		for _, k := range keys {
+			Insert(k.key, k.keyNumber, indexStructure)
+		}
+		if len(indexStructure.runs) == len(indexStructure.freeRuns) {
+			t.Fatalf("%s: the keys are held without runs", c.name)
+		}
+		for _, test := range tests {
+			matchFound, keyPrefix, indexes := LongestPrefix(test.keyInput, indexStructure)
+			if matchFound != test.wantFound || keyPrefix != test.wantPrefix ||
+				fmt.Sprint(indexes) != fmt.Sprint(test.want) {
+				t.Errorf("%s/%s: LongestPrefix(%q) gave %v %q %v, want %v %q %v", c.name, test.name, test.keyInput,
+					matchFound, keyPrefix, indexes, test.wantFound, test.wantPrefix, test.want)
+			}
+		}
+	}
+	//
+	var empty Index
+	Initialise(&empty)
+	if matchFound, keyPrefix, indexes := LongestPrefix("a", &empty); matchFound || keyPrefix != "" || indexes != nil {
+		t.Errorf("LongestPrefix on an empty index gave %v %q %v", matchFound, keyPrefix, indexes)
+	}
+}
+
+func TestLongestPrefixAgainstModel(t *testing.T) {
+	// the longest key of the model that the input starts with, as the index changes
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			indexStructure, m := c.index(), testModel{}
+			for x := 0; x < 10; x++ {
+				churn(t, indexStructure, m, r, "ab/", 12, 60)
+				for y := 0; y < 50; y++ {
+					keyInput := randomKey(r, "ab/", 14)
+					wantPrefix := ""
+					for entry := range m {
+						if strings.HasPrefix(keyInput, entry.key) && len(entry.key) > len(wantPrefix) {
+							wantPrefix = entry.key
+						}
+					}
+					want := m.numbers(func(key string) bool { return key == wantPrefix })
+					matchFound, keyPrefix, indexes := LongestPrefix(keyInput, indexStructure)
+					if matchFound != (wantPrefix != "") || keyPrefix != wantPrefix ||
+						fmt.Sprint(indexes) != fmt.Sprint(want) {
+						t.Fatalf("LongestPrefix(%q) gave %v %q %v, want %q %v", keyInput, matchFound, keyPrefix,
+							indexes, wantPrefix, want)
+					}
+				}
+			}
+		})
+	}
+}
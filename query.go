@@ -0,0 +1,292 @@
+package key
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	queryMatch = iota
+	queryAnd
+	queryOr
+	queryNot
+)
+
+const estimateLimit = 1 << 12 // entries counted at most when estimating what a Match finds
+
+// ErrQueryNot is the error of a query built with a Not anywhere other than as an operand of an And that has an
+// operand without a Not
+//
+var ErrQueryNot = errors.New("key: Not can only be an operand of And alongside one that is not a Not")
+
+// Query is a condition on one or more indexes that share the same index numbers, built from Match and Where and
+// combined with And, Or and Not
+//
+type Query struct {
+	operator int
+	search   func() (matchFound bool, indexes []int)
+	estimate func() int // how many index numbers "search" should find, or nil if there is no telling
+	operands []*Query
+	err      error
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Match is a query for what Search finds in the specified index
+//
+func Match(keyInput string, searchPrecisely bool, indexStructure *Index) *Query {
+	return &Query{
+		operator: queryMatch,
+		search:   func() (bool, []int) { return Search(keyInput, searchPrecisely, indexStructure) },
+		estimate: func() int { return estimateCount(keyInput, searchPrecisely, indexStructure) },
+	}
+}
+
+// Where is a query for what any other search finds -- "search" is called each time the query is run
+// there is no telling in advance how much it finds, so an And runs it after any Match operands
+//
+func Where(search func() (matchFound bool, indexes []int)) *Query {
+	return &Query{operator: queryMatch, search: search}
+}
+
+// And is a query for the index numbers every operand finds -- a Not operand takes what it finds away
+// at least one operand must not be a Not, otherwise the query's Err is ErrQueryNot
+//
+func And(operands ...*Query) *Query {
+	q := &Query{operator: queryAnd, operands: operands, err: operandError(operands)}
+	if q.err == nil && len(operands) > 0 {
+		q.err = ErrQueryNot
+		for _, operand := range operands {
+			if operand.operator != queryNot {
+				q.err = nil
+			}
+		}
+	}
+	return q
+}
+
+// Or is a query for the index numbers any operand finds -- a Not operand makes the query's Err ErrQueryNot, as
+// there is nothing for it to take index numbers away from
+//
+func Or(operands ...*Query) *Query {
+	q := &Query{operator: queryOr, operands: operands, err: operandError(operands)}
+	for _, operand := range operands {
+		if q.err == nil && operand.operator == queryNot {
+			q.err = ErrQueryNot
+		}
+	}
+	return q
+}
+
+// Not is a query for the index numbers its operand does not find, for use as an operand of And -- run on its own it
+// finds nothing, and Not(Not(q)) is q
+//
+func Not(operand *Query) *Query {
+	if operand.operator == queryNot {
+		return operand.operands[0]
+	}
+	return &Query{operator: queryNot, operands: []*Query{operand}, err: operand.err}
+}
+
+func operandError(operands []*Query) error {
+	for _, operand := range operands {
+		if operand.err != nil {
+			return operand.err
+		}
+	}
+	return nil
+}
+
+// Err returns what is wrong with how the query was built, if anything -- such a query finds nothing
+//
+func (q *Query) Err() error {
+	return q.err
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Run returns the index numbers the query finds in ascending order, each once only
+// an And runs its operands in order of how much each is expected to find, smallest first, and stops as soon as
+// nothing is left -- a query whose Err is not nil finds nothing
+// "matchFound" is "true" if something is located
+//
+func (q *Query) Run() (matchFound bool, indexes []int) {
+	if q.err != nil {
+		return
+	}
+	indexes = q.run()
+	matchFound = len(indexes) > 0
+	return
+}
+
+func (q *Query) run() (indexes []int) {
+	switch q.operator {
+	//
+	case queryMatch:
+		_, found := q.search()
+		indexes = sortedNumbers(found)
+	//
+	case queryOr:
+		for _, operand := range q.operands {
+			indexes = unionNumbers(indexes, operand.run())
+		}
+	//
+	case queryAnd:
+		var included, excluded []*Query
+		var estimates []int
+		for _, operand := range q.operands {
+			if operand.operator == queryNot {
+				excluded = append(excluded, operand.operands[0])
+			} else {
+				included = append(included, operand)
+				estimates = append(estimates, operand.estimated())
+			}
+		}
+		order := make([]int, len(included))
+		for x := range order {
+			order[x] = x
+		}
+		sort.SliceStable(order, func(i, j int) bool { return estimates[order[i]] < estimates[order[j]] })
+		for x, y := range order {
+			if set := included[y].run(); x == 0 {
+				indexes = set
+			} else {
+				indexes = intersectNumbers(indexes, set)
+			}
+			if len(indexes) == 0 {
+				return nil
+			}
+		}
+		for _, operand := range excluded {
+			if indexes = subtractNumbers(indexes, operand.run()); len(indexes) == 0 {
+				return nil
+			}
+		}
+	}
+	return
+}
+
+func (q *Query) estimated() (count int) {
+	// how many index numbers the query is expected to find, math.MaxInt when there is no telling
+	switch q.operator {
+	case queryMatch:
+		if q.estimate == nil {
+			return math.MaxInt
+		}
+		return q.estimate()
+	case queryAnd:
+		count = math.MaxInt
+		for _, operand := range q.operands {
+			if operand.operator != queryNot {
+				count = min(count, operand.estimated())
+			}
+		}
+	case queryOr:
+		for _, operand := range q.operands {
+			if count += operand.estimated(); count < 0 {
+				return math.MaxInt
+			}
+		}
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func estimateCount(keyInput string, searchPrecisely bool, indexStructure *Index) (count int) {
+	// the number of index numbers Search would find, counting no further than estimateLimit
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 && searchPrecisely {
+		return
+	}
+	found, keyPointer, keyPath := locate(keyField, indexStructure)
+	if (len(keyField) > 0 && !found) || keyPointer == nullIndexPointer {
+		return
+	}
+	walk(keyPointer, []byte(keyPath), indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			descend = !searchPrecisely || len(keyPath) <= len(keyField)
+			stop = count >= estimateLimit
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			if !searchPrecisely || len(keyPath) == len(keyField) {
+				count += len(keyNumbers)
+			}
+		})
+	return min(count, estimateLimit)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func sortedNumbers(indexes []int) (sorted []int) {
+	// ascending and without duplicates, leaving the input alone
+	sorted = append([]int(nil), indexes...)
+	sort.Ints(sorted)
+	n := 0
+	for i, keyNumber := range sorted {
+		if i == 0 || keyNumber != sorted[n-1] {
+			sorted[n] = keyNumber
+			n++
+		}
+	}
+	return sorted[:n]
+}
+
+func intersectNumbers(a, b []int) (both []int) {
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+	return
+}
+
+func unionNumbers(a, b []int) (either []int) {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			either = append(either, a[i])
+			i++
+		case a[i] > b[j]:
+			either = append(either, b[j])
+			j++
+		default:
+			either = append(either, a[i])
+			i++
+			j++
+		}
+	}
+	either = append(either, a[i:]...)
+	return append(either, b[j:]...)
+}
+
+func subtractNumbers(a, b []int) (left []int) {
+	j := 0
+	for _, keyNumber := range a {
+		for j < len(b) && b[j] < keyNumber {
+			j++
+		}
+		if j == len(b) || b[j] != keyNumber {
+			left = append(left, keyNumber)
+		}
+	}
+	return
+}
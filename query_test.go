@@ -0,0 +1,140 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func queryIndexes() (name, city *Index) {
+	// entry x is a person with a name and a city
+	name, city = &Index{}, &Index{}
+	Initialise(name)
+	Initialise(city)
+	people := []struct{ name, city string }{
+		{"smith", "york"}, {"smithers", "leeds"}, {"jones", "york"}, {"smith", "leeds"}, {"brown", "york"},
+		{"smyth", "hull"}, {"jones", "hull"},
+	}
+	for x, person := range people {
+		Insert(person.name, x, name)
+		Insert(person.city, x, city)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestQuery(t *testing.T) {
+	name, city := queryIndexes()
+	tests := []struct {
+		name  string
+		query *Query
+		want  []int
+		err   error
+	}{
+		{name: "match", query: Match("smith", true, name), want: []int{0, 3}},
+		{name: "global", query: Match("smith", false, name), want: []int{0, 1, 3}},
+		{name: "and", query: And(Match("smith", false, name), Match("york", true, city)), want: []int{0}},
+		{name: "or", query: Or(Match("jones", true, name), Match("hull", true, city)), want: []int{2, 5, 6}},
+		{name: "and not", query: And(Match("york", true, city), Not(Match("smith", true, name))), want: []int{2, 4}},
+		{name: "not not", query: And(Match("york", true, city), Not(Not(Match("smith", true, name)))),
+			want: []int{0}},
+		{name: "not not alone", query: Not(Not(Match("jones", true, name))), want: []int{2, 6}},
+		{name: "not of an or", query: And(Match("", false, city), Not(Or(Match("york", true, city),
+			Match("hull", true, city)))), want: []int{1, 3}},
+		{name: "nothing left", query: And(Match("brown", true, name), Match("leeds", true, city),
+			Match("smith", true, name)), want: nil},
+		{name: "missing", query: And(Match("nobody", true, name), Match("york", true, city)), want: nil},
+		{name: "not alone", query: Not(Match("york", true, city)), want: nil},
+		{name: "not in or", query: Or(Match("york", true, city), Not(Match("smith", true, name))),
+			err: ErrQueryNot},
+		{name: "only not in and", query: And(Not(Match("york", true, city)), Not(Match("smith", true, name))),
+			err: ErrQueryNot},
+		{name: "error inside", query: And(Match("york", true, city), Or(Match("a", false, name),
+			Not(Match("smith", true, name)))), err: ErrQueryNot},
+		{name: "error inside not", query: And(Match("york", true, city), Not(Or(Match("a", false, name),
+			Not(Match("smith", true, name))))), err: ErrQueryNot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.query.Err(); !errors.Is(err, test.err) {
+				t.Fatalf("Err gave %v, want %v", err, test.err)
+			}
+			matchFound, got := test.query.Run()
+			if matchFound != (len(test.want) > 0) || fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("Run gave %v %v, want %v", matchFound, got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryPlan(t *testing.T) {
+	// an And runs its operands smallest estimate first, leaves a Where until after them, and runs nothing once
+	// nothing is left
+	name, city := queryIndexes()
+	var ran []string
+	traced := func(label string, q *Query) *Query {
+		search := q.search
+		q.search = func() (bool, []int) {
+			ran = append(ran, label)
+			return search()
+		}
+		return q
+	}
+	where := func(label string, indexes ...int) *Query {
+		return Where(func() (bool, []int) {
+			ran = append(ran, label)
+			return len(indexes) > 0, indexes
+		})
+	}
+	tests := []struct {
+		name  string
+		query *Query
+		want  string
+	}{
+		{name: "smallest first", query: And(traced("york", Match("york", true, city)),
+			traced("brown", Match("brown", true, name)), traced("global", Match("", false, name))),
+			want: "[brown york global]"},
+		{name: "where last", query: And(where("where", 0, 2, 4), traced("york", Match("york", true, city)),
+			traced("jones", Match("jones", true, name))), want: "[jones york where]"},
+		{name: "stops when empty", query: And(traced("york", Match("york", true, city)),
+			traced("hull", Match("hull", true, city)), where("where", 1)), want: "[hull york]"},
+		{name: "missing never runs the rest", query: And(traced("york", Match("york", true, city)),
+			traced("nobody", Match("nobody", false, name)), where("where", 1)), want: "[nobody]"},
+		{name: "nested estimates", query: And(Or(traced("york", Match("york", true, city)),
+			traced("leeds", Match("leeds", true, city))), And(traced("smith", Match("smith", false, name)),
+			traced("hull", Match("hull", true, city)))), want: "[hull smith]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ran = nil
+			test.query.Run()
+			if fmt.Sprint(ran) != test.want {
+				t.Errorf("ran %v, want %v", ran, test.want)
+			}
+		})
+	}
+}
+
+func TestEstimateCount(t *testing.T) {
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			indexStructure, m := c.index(), testModel{}
+			for x, keyInput := range []string{"abcdefgh", "abcd", "abcd", "abx", "b", "abcdefghij"} {
+				Insert(keyInput, x, indexStructure)
+				m.insert(keyInput, x)
+			}
+			for _, keyInput := range []string{"", "a", "abc", "abcd", "abcde", "abcdefgh", "b", "z", "abcdefghijk"} {
+				for _, searchPrecisely := range []bool{false, true} {
+					_, indexes := Search(keyInput, searchPrecisely, indexStructure)
+					if count := estimateCount(keyInput, searchPrecisely, indexStructure); count != len(indexes) {
+						t.Errorf("estimateCount(%q, %v) gave %d, want %d", keyInput, searchPrecisely, count,
+							len(indexes))
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package key
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// QueryError describes what is wrong with the text given to ParseQuery and where
+//
+type QueryError struct {
+	Offset  int // bytes into the text
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query: %s at offset %d", e.Message, e.Offset)
+}
+
+type queryParser struct {
+	text   string
+	i      int
+	fields map[string]*Index
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// ParseQuery turns text such as
+//
+//	name:smi* AND city="New York" AND NOT (age:[30 TO 40] OR age=99)
+//
+// into a Query, each field naming one of the indexes in "fields"
+//
+//	field=value       a precise Search
+//	field:value       a precise Search, or a global Search if the only wildcard is a final '*', or SearchPattern if
+//	                  the value holds any other '*', '?', '[' or '\'
+//	field:[low TO high]  SearchRange, or SearchIntRange for integer bounds -- a '*' bound is open
+//
+// a value with spaces or brackets in it goes in double quotes, where '\' makes the next character an ordinary one,
+// and a quoted value is never a pattern, an integer or an open bound
+// an unquoted integer value finds keys filed under that text and keys filed by InsertInt under that number -- a range
+// whose bounds are unquoted integers, or one integer and '*', is SearchIntRange and so only finds keys filed by
+// InsertInt, compared as numbers, while any other range compares keys as strings of bytes, so ["30" TO "40"] takes in
+// "4" and "300"
+// NOT binds tightest, then AND, then OR -- NOT can only be used as an operand of AND, since there is nothing else for
+// it to take index numbers away from
+// the error returned for text that cannot be parsed is a *QueryError
+//
+func ParseQuery(text string, fields map[string]*Index) (query *Query, err error) {
+	p := &queryParser{text: text, fields: fields}
+	if query, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.i < len(p.text) {
+		return nil, p.fail("expected AND, OR or the end of the query")
+	}
+	if query.operator == queryNot {
+		return nil, p.failAt(0, "NOT must be combined with AND")
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (p *queryParser) parseOr() (query *Query, err error) {
+	var operands []*Query
+	for {
+		start := p.i
+		var operand *Query
+		if operand, err = p.parseAnd(); err != nil {
+			return
+		}
+		operands = append(operands, operand)
+		if operand.operator == queryNot && (len(operands) > 1 || p.keyword("OR")) {
+			return nil, p.failAt(start, "NOT must be combined with AND")
+		}
+		if !p.keyword("OR") {
+			break
+		}
+		p.i += len("OR")
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return Or(operands...), nil
+}
+
+func (p *queryParser) parseAnd() (query *Query, err error) {
+	var operands []*Query
+	positive := false
+	for {
+		var operand *Query
+		if operand, err = p.parseNot(); err != nil {
+			return
+		}
+		operands = append(operands, operand)
+		positive = positive || operand.operator != queryNot
+		if !p.keyword("AND") {
+			break
+		}
+		p.i += len("AND")
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	if !positive {
+		return nil, p.fail("AND needs at least one operand without NOT")
+	}
+	return And(operands...), nil
+}
+
+func (p *queryParser) parseNot() (query *Query, err error) {
+	if p.keyword("NOT") {
+		p.i += len("NOT")
+		if p.keyword("NOT") {
+			return nil, p.fail("NOT cannot follow NOT")
+		}
+		if query, err = p.parseNot(); err != nil {
+			return
+		}
+		return Not(query), nil
+	}
+	if p.skipSpace(); p.i < len(p.text) && p.text[p.i] == '(' {
+		p.i++
+		if query, err = p.parseOr(); err != nil {
+			return
+		}
+		if p.skipSpace(); p.i == len(p.text) || p.text[p.i] != ')' {
+			return nil, p.fail("expected ')'")
+		}
+		p.i++
+		return
+	}
+	return p.parseCondition()
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (p *queryParser) parseCondition() (query *Query, err error) {
+	p.skipSpace()
+	start := p.i
+	for p.i < len(p.text) && fieldCharacter(p.text[p.i]) {
+		p.i++
+	}
+	if p.i == start {
+		return nil, p.fail("expected a field name")
+	}
+	name := p.text[start:p.i]
+	indexStructure, exists := p.fields[name]
+	if !exists {
+		return nil, p.failAt(start, fmt.Sprintf("unknown field %q", name))
+	}
+	if p.i == len(p.text) || (p.text[p.i] != ':' && p.text[p.i] != '=') {
+		return nil, p.fail("expected ':' or '=' after " + name)
+	}
+	operator := p.text[p.i]
+	p.i++
+	//
+	if operator == ':' && p.i < len(p.text) && p.text[p.i] == '[' {
+		p.i++
+		var low, high string
+		var lowQuoted, highQuoted bool
+		if low, lowQuoted, err = p.parseValue(" ]"); err != nil {
+			return
+		}
+		if !p.keyword("TO") {
+			return nil, p.fail("expected TO in the range")
+		}
+		p.i += len("TO")
+		if p.skipSpace(); p.i < len(p.text) && p.text[p.i] == ']' {
+			return nil, p.fail("expected a value")
+		}
+		if high, highQuoted, err = p.parseValue(" ]"); err != nil {
+			return
+		}
+		if p.skipSpace(); p.i == len(p.text) || p.text[p.i] != ']' {
+			return nil, p.fail("expected ']' to close the range")
+		}
+		p.i++
+		lowInt, lowNumeric := integerBound(low, lowQuoted, math.MinInt64)
+		highInt, highNumeric := integerBound(high, highQuoted, math.MaxInt64)
+		if lowNumeric && highNumeric && (low != "*" || high != "*") {
+			return Where(func() (bool, []int) { return SearchIntRange(lowInt, highInt, indexStructure) }), nil
+		}
+		if low == "*" && !lowQuoted {
+			low = ""
+		}
+		if high == "*" && !highQuoted {
+			high = ""
+		}
+		return Where(func() (bool, []int) { return SearchRange(low, high, indexStructure) }), nil
+	}
+	//
+	value, quoted, err := p.parseValue(" ()")
+	if err != nil {
+		return
+	}
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil && !quoted { // filed as text or by InsertInt
+		return Or(Match(value, true, indexStructure), Match(IntKey(number), true, indexStructure)), nil
+	}
+	if operator == '=' || quoted || !strings.ContainsAny(value, "*?[\\") {
+		return Match(value, true, indexStructure), nil
+	}
+	if keyPrefix := strings.TrimSuffix(value, "*"); !strings.ContainsAny(keyPrefix, "*?[\\") {
+		return Match(keyPrefix, false, indexStructure), nil
+	}
+	return Where(func() (bool, []int) { return SearchPattern(value, indexStructure) }), nil
+}
+
+func (p *queryParser) parseValue(stops string) (value string, quoted bool, err error) {
+	// a quoted string, or the characters up to white space or one of "stops"
+	if p.skipSpace(); p.i < len(p.text) && p.text[p.i] == '"' {
+		start := p.i
+		var b strings.Builder
+		for p.i++; p.i < len(p.text) && p.text[p.i] != '"'; p.i++ {
+			if p.text[p.i] == '\\' && p.i+1 < len(p.text) {
+				p.i++
+			}
+			b.WriteByte(p.text[p.i])
+		}
+		if p.i == len(p.text) {
+			return "", true, p.failAt(start, "quoted value is never closed")
+		}
+		p.i++
+		return b.String(), true, nil
+	}
+	start := p.i
+	for p.i < len(p.text) && !strings.ContainsRune(stops, rune(p.text[p.i])) && !isSpace(p.text[p.i]) {
+		p.i++
+	}
+	if p.i == start {
+		return "", false, p.fail("expected a value")
+	}
+	return p.text[start:p.i], false, nil
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (p *queryParser) keyword(word string) bool {
+	// skips white space and reports whether "word" comes next, standing on its own
+	p.skipSpace()
+	end := p.i + len(word)
+	if end > len(p.text) || p.text[p.i:end] != word {
+		return false
+	}
+	return end == len(p.text) || isSpace(p.text[end]) || p.text[end] == '(' || p.text[end] == '"'
+}
+
+func (p *queryParser) skipSpace() {
+	for p.i < len(p.text) && isSpace(p.text[p.i]) {
+		p.i++
+	}
+}
+
+func (p *queryParser) fail(message string) error {
+	return p.failAt(p.i, message)
+}
+
+func (p *queryParser) failAt(offset int, message string) error {
+	return &QueryError{Offset: offset, Message: message}
+}
+
+func integerBound(value string, quoted bool, open int64) (bound int64, numeric bool) {
+	// the integer an unquoted range bound stands for -- '*' stands for the open end
+	if quoted {
+		return
+	}
+	if value == "*" {
+		return open, true
+	}
+	bound, err := strconv.ParseInt(value, 10, 64)
+	return bound, err == nil
+}
+
+func isSpace(character byte) bool {
+	return character == ' ' || character == '\t' || character == '\n' || character == '\r'
+}
+
+func fieldCharacter(character byte) bool {
+	return character == '_' || character == '-' || character == '.' || '0' <= character && character <= '9' ||
+		'a' <= character && character <= 'z' || 'A' <= character && character <= 'Z'
+}
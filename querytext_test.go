@@ -0,0 +1,79 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	// entry x is a person with a name, a city, an age filed by InsertInt and a room number filed as text
+	name, city, age, room := &Index{}, &Index{}, &Index{}, &Index{}
+	for _, indexStructure := range []*Index{name, city, age, room} {
+		Initialise(indexStructure)
+	}
+	people := []struct {
+		name, city string
+		age        int64
+		room       string
+	}{
+		{"smith", "new york", 30, "4"}, {"smithers", "leeds", 4, "30"}, {"jones", "york", 35, "300"},
+		{"smith", "leeds", 40, "35"}, {"brown", "york", 300, "40"}, {"smyth", "hull", -5, "99"},
+	}
+	for x, person := range people {
+		Insert(person.name, x, name)
+		Insert(person.city, x, city)
+		InsertInt(person.age, x, age)
+		Insert(person.room, x, room)
+	}
+	fields := map[string]*Index{"name": name, "city": city, "age": age, "room": room}
+	//
+	tests := []struct {
+		text string
+		want []int
+		err  string // the message of the *QueryError
+	}{
+		{text: "name=smith", want: []int{0, 3}},
+		{text: "name:smi*", want: []int{0, 1, 3}},
+		{text: "name:sm?th", want: []int{0, 3, 5}},
+		{text: `city="new york"`, want: []int{0}},
+		{text: "name:smith AND NOT city=leeds", want: []int{0}},
+		{text: "city=york OR city=hull", want: []int{2, 4, 5}},
+		{text: "NOT (city=york OR city=hull) AND name:s*", want: []int{0, 1, 3}},
+		{text: "age:[30 TO 40]", want: []int{0, 2, 3}},
+		{text: "age:[* TO 30]", want: []int{0, 1, 5}},
+		{text: "age:[35 TO *]", want: []int{2, 3, 4}},
+		{text: "age:[-10 TO 4]", want: []int{1, 5}},
+		{text: "age:[40 TO 30]", want: nil},
+		{text: "age=300 OR age:35", want: []int{2, 4}},
+		{text: "room=300", want: []int{2}},
+		{text: `room:["30" TO "40"]`, want: []int{0, 1, 2, 3, 4}},
+		{text: "room:[30 TO 40]", want: nil}, // rooms are not filed by InsertInt
+		{text: `room:[* TO "35"]`, want: []int{1, 2, 3}},
+		{text: "room:[* TO *]", want: []int{0, 1, 2, 3, 4, 5}},
+		{text: "nosuch=1", err: `unknown field "nosuch"`},
+		{text: "name=smith OR NOT city=york", err: "NOT must be combined with AND"},
+		{text: "NOT name=smith", err: "NOT must be combined with AND"},
+		{text: "NOT name=a AND NOT name=b", err: "AND needs at least one operand without NOT"},
+		{text: "age:[1 40]", err: "expected TO in the range"},
+		{text: "(name=smith", err: "expected ')'"},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			query, err := ParseQuery(test.text, fields)
+			if test.err != "" {
+				var queryError *QueryError
+				if !errors.As(err, &queryError) || queryError.Message != test.err {
+					t.Fatalf("ParseQuery gave %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, got := query.Run(); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("Run gave %v, want %v", got, test.want)
+			}
+		})
+	}
+}
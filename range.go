@@ -0,0 +1,38 @@
+package key
+
+import (
+	"bytes"
+)
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchRange returns the index numbers of every key from "lowKey" to "highKey" inclusive, in ascending key order
+// a blank "lowKey" starts at the beginning of the index and a blank "highKey" runs to the end
+// "matchFound" is "true" if something is located
+//
+func SearchRange(lowKey, highKey string, indexStructure *Index) (matchFound bool, indexes []int) {
+	low := []byte(trimKey(lowKey))
+	high := []byte(trimKey(highKey))
+	if indexStructure.indexRoot == nullIndexPointer {
+		return
+	}
+	//
+	walk(indexStructure.indexRoot, nil, indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			if len(high) > 0 && bytes.Compare(keyPath, high[:min(len(keyPath), len(high))]) > 0 {
+				stop = true // everything from here on is beyond the range
+				return
+			}
+			descend = bytes.Compare(keyPath, low[:min(len(keyPath), len(low))]) >= 0
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			if bytes.Compare(keyPath, low) >= 0 && (len(high) == 0 || bytes.Compare(keyPath, high) <= 0) {
+				indexes = append(indexes, keyNumbers...)
+			}
+		})
+	matchFound = len(indexes) > 0
+	return
+}
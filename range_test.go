@@ -0,0 +1,39 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSearchRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		low, high string
+	}{
+		{name: "everything", low: "", high: ""},
+		{name: "from", low: "b", high: ""},
+		{name: "to", low: "", high: "b"},
+		{name: "between", low: "ab", high: "ca"},
+		{name: "one key", low: "ab", high: "ab"},
+		{name: "empty", low: "ca", high: "ab"},
+		{name: "between keys", low: "aab", high: "aac"},
+		{name: "trimmed", low: " b ", high: " c "},
+	}
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			indexStructure, m := c.index(), testModel{}
+			churn(t, indexStructure, m, r, "abc", 4, 300)
+			for _, test := range tests {
+				low, high := trimKey(test.low), trimKey(test.high)
+				want := m.numbers(func(key string) bool { return key >= low && (high == "" || key <= high) })
+				matchFound, got := SearchRange(test.low, test.high, indexStructure)
+				if matchFound != (len(want) > 0) || fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("%s: SearchRange(%q, %q) gave %v %v, want %v", test.name, test.low, test.high,
+						matchFound, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package key
+
+import (
+	"fmt"
+)
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// RecoveryReport contains the results of a Recover
+//
+type RecoveryReport struct {
+	Nodes       int         // nodes in the damaged structure
+	Recovered   int         // key and "index-number" pairs rebuilt into the new structure
+	Unreachable int         // nodes in neither the index nor the free list
+	Lost        []LostEntry // "index-numbers" found in detached branches whose full key could not be read
+	Damage      []string    // the breaks that stopped the scan
+}
+
+// LostEntry is an "index-number" found in a detached branch together with the tail of its key that could be read
+//
+type LostEntry struct {
+	Fragment  string
+	KeyNumber int
+}
+
+type recoveredEntry struct {
+	key       string
+	keyNumber int
+}
+
+type salvager struct {
+	indexStructure *Index
+	state          []byte
+	entries        []recoveredEntry
+	report         *RecoveryReport
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (s *salvager) reach(keyPointer, fromPointer int) bool {
+	if keyPointer < 0 || keyPointer >= s.indexStructure.nodeCount() {
+		s.report.Damage = append(s.report.Damage,
+			fmt.Sprintf("node %d: pointer %d is out of range", fromPointer, keyPointer))
+		return false
+	}
+	if s.state[keyPointer] != nodeUnseen {
+		s.report.Damage = append(s.report.Damage,
+			fmt.Sprintf("node %d: node %d is reached more than once", fromPointer, keyPointer))
+		return false
+	}
+	s.state[keyPointer] = nodeInIndex
+	return true
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (s *salvager) branch(keyPointer, fromPointer int, keyPath []byte, duplicate bool, found func([]byte, int)) {
+	// follows the left and right pointers only -- the threads are ignored so a broken thread loses nothing
+	if !s.reach(keyPointer, fromPointer) {
+		return
+	}
+	node := s.indexStructure.nodeAt(keyPointer)
+	if node.status == 'P' {
+		if node.leftPointer < 0 || node.leftPointer >= len(s.indexStructure.runs) {
+			s.report.Damage = append(s.report.Damage, fmt.Sprintf("node %d: run %d is out of range", keyPointer,
+				node.leftPointer))
+			return
+		}
+		keyPath = append(keyPath, s.indexStructure.runs[node.leftPointer]...)
+	} else if node.status != 'D' {
+		keyPath = append(keyPath, node.key)
+		if (duplicate && len(keyPath) > maxNumberLength) || (!duplicate && len(keyPath) > maxKeyLength) {
+			s.report.Damage = append(s.report.Damage, fmt.Sprintf("node %d: branch is too deep", keyPointer))
+			return
+		}
+	}
+	//
+	switch node.status {
+	//
+	case 'D':
+		s.branch(node.leftPointer, keyPointer, keyPath, duplicate, found)
+		s.branch(node.rightPointer, keyPointer, keyPath, duplicate, found)
+	//
+	case 'X', 'P':
+		s.branch(node.rightPointer, keyPointer, keyPath, duplicate, found)
+	//
+	case 'R', 'S':
+		found(keyPath, node.leftPointer)
+		if node.status == 'R' {
+			s.branch(node.rightPointer, keyPointer, keyPath, duplicate, found)
+		}
+	//
+	case 'K', 'L':
+		if duplicate {
+			s.report.Damage = append(s.report.Damage,
+				fmt.Sprintf("node %d: duplicate branch contains a duplicate", keyPointer))
+			return
+		}
+		key := string(keyPath)
+		s.branch(node.leftPointer, keyPointer, nil, true, func(_ []byte, keyNumber int) {
+			found([]byte(key), keyNumber)
+		})
+		if node.status == 'K' {
+			s.branch(node.rightPointer, keyPointer, keyPath, duplicate, found)
+		}
+	//
+	default:
+		s.report.Damage = append(s.report.Damage, fmt.Sprintf("node %d: unknown status %q", keyPointer, node.status))
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Recover pulls every key and "index-number" that can still be reached out of a damaged index structure and
+// rebuilds the structure from them
+// branches that have come adrift from the tree are scanned as well and their "index-numbers" are returned in the
+// report along with whatever tail of the key could be read -- the report also lists the damage found
+//
+func Recover(indexStructure *Index) (report RecoveryReport) {
+	s := salvager{
+		indexStructure: indexStructure,
+		state:          make([]byte, indexStructure.nodeCount()),
+		report:         &report,
+	}
+	report.Nodes = indexStructure.nodeCount()
+	//
+	if indexStructure.indexRoot != nullIndexPointer {
+		s.branch(indexStructure.indexRoot, nullIndexPointer, nil, false, func(keyPath []byte, keyNumber int) {
+			s.entries = append(s.entries, recoveredEntry{key: string(keyPath), keyNumber: keyNumber})
+		})
+	}
+	//
+	// mark the free list so that deleted nodes are not mistaken for detached branches -- the whole chain is followed,
+	// past any node that is in the index as well, until it ends, leaves the node array or comes back on itself
+	onChain := make([]bool, indexStructure.nodeCount())
+	fromPointer := nullIndexPointer
+	for x := indexStructure.deletedRoot; x != nullIndexPointer; x = indexStructure.nodeAt(x).rightPointer {
+		if x < 0 || x >= indexStructure.nodeCount() {
+			report.Damage = append(report.Damage, fmt.Sprintf("node %d: free list pointer %d is out of range",
+				fromPointer, x))
+			break
+		}
+		if onChain[x] {
+			report.Damage = append(report.Damage, fmt.Sprintf("node %d: free list loops back to node %d",
+				fromPointer, x))
+			break
+		}
+		onChain[x] = true
+		if s.state[x] == nodeInIndex {
+			report.Damage = append(report.Damage, fmt.Sprintf("node %d: node %d is both in the index and on the "+
+				"free list", fromPointer, x))
+		} else {
+			s.state[x] = nodeOnFreeList
+		}
+		fromPointer = x
+	}
+	//
+	for x := range s.state {
+		if s.state[x] == nodeUnseen {
+			report.Unreachable++
+		}
+	}
+	//
+	// a detached branch starts at a node that no other unclaimed node points down to
+	pointedTo := make([]bool, indexStructure.nodeCount())
+	for x := range pointedTo {
+		node := indexStructure.nodeAt(x)
+		if s.state[x] != nodeUnseen {
+			continue
+		}
+		if node.status == 'D' || node.status == 'K' || node.status == 'L' {
+			if node.leftPointer >= 0 && node.leftPointer < len(pointedTo) {
+				pointedTo[node.leftPointer] = true
+			}
+		}
+		if node.status == 'D' || node.status == 'X' || node.status == 'P' || node.status == 'R' || node.status == 'K' {
+			if node.rightPointer >= 0 && node.rightPointer < len(pointedTo) {
+				pointedTo[node.rightPointer] = true
+			}
+		}
+	}
+	for x := range pointedTo {
+		if s.state[x] != nodeUnseen || pointedTo[x] {
+			continue
+		}
+		s.branch(x, nullIndexPointer, nil, false, func(keyPath []byte, keyNumber int) {
+			report.Lost = append(report.Lost, LostEntry{Fragment: string(keyPath), KeyNumber: keyNumber})
+		})
+	}
+	//
+	var rebuilt Index
+	InitialiseLayout(&rebuilt, IndexLayout(indexStructure))
+	allocation, trim := IndexAllocation(indexStructure)
+	SetAllocation(&rebuilt, allocation, trim)
+	for _, entry := range s.entries {
+		Insert(entry.key, entry.keyNumber, &rebuilt)
+	}
+	if indexStructure.suffixes != nil {
+		EnableSuffixes(&rebuilt)
+	}
+	if indexStructure.weights != nil {
+		for entry, weight := range indexStructure.weights.weight {
+			SetWeight(entry.key, entry.keyNumber, weight, &rebuilt)
+		}
+	}
+	rebuilt.observers = indexStructure.observers
+	*indexStructure = rebuilt
+	report.Recovered = len(s.entries)
+	return
+}
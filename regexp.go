@@ -0,0 +1,209 @@
+package key
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+type regexpState struct {
+	threads  []uint32 // instructions waiting for the next character
+	previous rune     // last complete character, or -1 at the start of the key
+	pending  []byte   // bytes of a character that is not yet complete
+}
+
+type regexpRunner struct {
+	program *syntax.Prog
+	seen    []bool
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (m *regexpRunner) follow(pc uint32, context syntax.EmptyOp, ready []uint32) []uint32 {
+	// adds "pc" and every instruction reachable from it without reading a character
+	if m.seen[pc] {
+		return ready
+	}
+	m.seen[pc] = true
+	inst := &m.program.Inst[pc]
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		ready = m.follow(inst.Out, context, ready)
+		ready = m.follow(inst.Arg, context, ready)
+	case syntax.InstCapture, syntax.InstNop:
+		ready = m.follow(inst.Out, context, ready)
+	case syntax.InstEmptyWidth:
+		if syntax.EmptyOp(inst.Arg)&^context == 0 {
+			ready = m.follow(inst.Out, context, ready)
+		}
+	case syntax.InstMatch, syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+		ready = append(ready, pc)
+	}
+	return ready
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (m *regexpRunner) closure(threads []uint32, context syntax.EmptyOp) (ready []uint32) {
+	for i := range m.seen {
+		m.seen[i] = false
+	}
+	for _, pc := range threads {
+		ready = m.follow(pc, context, ready)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (m *regexpRunner) advance(state *regexpState, r rune) {
+	var next []uint32
+	for _, pc := range m.closure(state.threads, syntax.EmptyOpContext(state.previous, r)) {
+		inst := &m.program.Inst[pc]
+		switch inst.Op {
+		case syntax.InstRune, syntax.InstRune1:
+			if inst.MatchRune(r) {
+				next = append(next, inst.Out)
+			}
+		case syntax.InstRuneAny:
+			next = append(next, inst.Out)
+		case syntax.InstRuneAnyNotNL:
+			if r != '\n' {
+				next = append(next, inst.Out)
+			}
+		}
+	}
+	state.threads = next
+	state.previous = r
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (m *regexpRunner) step(from *regexpState, character byte) (to regexpState) {
+	// feeds one byte of the key to the program -- characters are decoded from UTF-8 the same way the regexp
+	// package does, so a byte that cannot start a character is read on its own as utf8.RuneError
+	to.threads = from.threads
+	to.previous = from.previous
+	to.pending = append(append([]byte(nil), from.pending...), character)
+	for len(to.pending) > 0 && utf8.FullRune(to.pending) {
+		r, size := utf8.DecodeRune(to.pending)
+		to.pending = to.pending[size:]
+		m.advance(&to, r)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (m *regexpRunner) matched(state regexpState) bool {
+	// a key that ends part way through a character reads the leftover bytes one at a time
+	for len(state.pending) > 0 {
+		r, size := utf8.DecodeRune(state.pending)
+		state.pending = state.pending[size:]
+		m.advance(&state, r)
+	}
+	for _, pc := range m.closure(state.threads, syntax.EmptyOpContext(state.previous, -1)) {
+		if m.program.Inst[pc].Op == syntax.InstMatch {
+			return true
+		}
+	}
+	return false
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchRegexp returns the index numbers of every key matched in full by a compiled regular expression, in
+// ascending key order -- the expression is anchored at both ends of the key, as if it were written "\A(?:...)\z"
+// the expression's automaton is run along the tree one character at a time so that a branch no key in which can
+// match is never entered, and "re.LiteralPrefix()" is looked up directly
+// the automaton is built from "re.String()" read with the flags regexp.Compile uses, as a Regexp gives up neither its
+// flags nor its program, so an expression compiled with regexp.CompilePOSIX, or any other flags, should be parsed
+// with them and given to SearchRegexpSyntax instead -- leftmost-first or leftmost-longest makes no difference, as
+// only whether the whole key matches is asked
+// "matchFound" is "true" if something is located
+//
+func SearchRegexp(re *regexp.Regexp, indexStructure *Index) (matchFound bool, indexes []int) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil { // cannot happen with an expression that has already been compiled
+		return
+	}
+	program, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return
+	}
+	literal, _ := re.LiteralPrefix()
+	return searchProgram(program, literal, indexStructure)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchRegexpSyntax returns the index numbers of every key matched in full by a parsed regular expression, in
+// ascending key order -- the expression keeps the flags it was parsed with and is anchored at both ends of the key,
+// and is searched for as SearchRegexp searches, the literal prefix of its program standing in for LiteralPrefix
+// "matchFound" is "true" if something is located
+//
+func SearchRegexpSyntax(parsed *syntax.Regexp, indexStructure *Index) (matchFound bool, indexes []int) {
+	program, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return
+	}
+	literal, _ := program.Prefix()
+	return searchProgram(program, literal, indexStructure)
+}
+
+func searchProgram(program *syntax.Prog, literal string, indexStructure *Index) (matchFound bool, indexes []int) {
+	// every match of the program starts with "literal", so only the keys starting with it are walked
+	if indexStructure.indexRoot == nullIndexPointer {
+		return
+	}
+	m := regexpRunner{program: program, seen: make([]bool, len(program.Inst))}
+	states := make([]regexpState, 1, maxKeyLength+1)
+	states[0] = regexpState{threads: []uint32{uint32(program.Start)}, previous: -1}
+	//
+	keyPointer := indexStructure.indexRoot
+	var prefix []byte
+	if len(literal) > 0 {
+		found, foundPointer, keyPath := locate(literal, indexStructure)
+		if !found {
+			return
+		}
+		keyPointer, prefix = foundPointer, []byte(keyPath)
+		for _, character := range prefix {
+			states = append(states, m.step(&states[len(states)-1], character))
+		}
+	}
+	//
+	walk(keyPointer, prefix, indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			depth := len(keyPath)
+			state := m.step(&states[depth-1], keyPath[depth-1])
+			if len(states) <= depth {
+				states = append(states, state)
+			} else {
+				states[depth] = state
+			}
+			descend = len(state.threads) > 0
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			if m.matched(states[len(keyPath)]) {
+				indexes = append(indexes, keyNumbers...)
+			}
+		})
+	matchFound = len(indexes) > 0
+	return
+}
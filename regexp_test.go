@@ -0,0 +1,60 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestSearchRegexp(t *testing.T) {
+	// every key the expression matches in full, checked against the regexp package run over the keys one at a time
+	tests := []struct {
+		name  string
+		expr  string
+		posix bool
+	}{
+		{name: "literal", expr: "abc"},
+		{name: "prefix", expr: "ab.*"},
+		{name: "alternation", expr: "a|ba|c+"},
+		{name: "classes", expr: `[ab]\d?[^b]`},
+		{name: "anchored inside", expr: `^a.*b$`},
+		{name: "word boundary", expr: `a\b.*`},
+		{name: "fold case", expr: "(?i)AB.*"},
+		{name: "not a newline", expr: "[^x]*"},
+		{name: "posix not a newline", expr: "[^x]*", posix: true},
+		{name: "posix line anchors", expr: "a$\n^b", posix: true},
+		{name: "posix longest", expr: "(a|ab)(c|bcd)?", posix: true},
+		{name: "utf-8", expr: "é.|.\\xff"},
+	}
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			indexStructure, m := c.index(), testModel{}
+			churn(t, indexStructure, m, rand.New(rand.NewSource(1)), "abc1\n\xc3\xa9\xff", 5, 400)
+			for _, test := range tests {
+				var matched func(keyField string) bool
+				var got []int
+				if test.posix {
+					re := regexp.MustCompilePOSIX(test.expr)
+					matched = func(keyField string) bool {
+						// leftmost-longest finds the whole key if anything does
+						location := re.FindStringIndex(keyField)
+						return location != nil && location[0] == 0 && location[1] == len(keyField)
+					}
+					parsed, err := syntax.Parse(test.expr, syntax.POSIX)
+					if err != nil {
+						t.Fatal(err)
+					}
+					_, got = SearchRegexpSyntax(parsed, indexStructure)
+				} else {
+					matched = regexp.MustCompile(`\A(?:` + test.expr + `)\z`).MatchString
+					_, got = SearchRegexp(regexp.MustCompile(test.expr), indexStructure)
+				}
+				if want := m.numbers(matched); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("%s: gave %v, want %v", test.name, got, want)
+				}
+			}
+		})
+	}
+}
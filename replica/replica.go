@@ -0,0 +1,480 @@
+// Package replica keeps copies of an index in other processes up to date with the index of a leader
+//
+// the leader numbers every change to its index and keeps the latest ones -- a follower connects, says which leader
+// it last heard from and the number of the last change it applied, and is sent the changes it missed, or a snapshot
+// of the whole index made by Save when the leader no longer has them, followed by every change as it is made
+// a follower that loses its connection dials again and carries on from where it stopped
+package replica
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/apwoodhouse/key"
+)
+
+const (
+	helloMagic = "KEYREPL1"
+	//
+	recordInsert   = 'I' // sequence, "index-number", key
+	recordDelete   = 'D'
+	recordSnapshot = 'S' // sequence, leader, length of the saved index, saved index
+)
+
+// DefaultBacklog is the number of changes a leader keeps for followers that fall behind or reconnect
+//
+const DefaultBacklog = 65536
+
+// DefaultMaxSnapshot is the length in bytes of the longest saved index a follower takes from its leader
+//
+const DefaultMaxSnapshot = 64 << 20
+
+// ErrBadStream is returned by a follower when the leader sends something that is not a change stream, a change
+// out of sequence or a snapshot longer than a follower takes
+//
+var ErrBadStream = errors.New("replica: bad change stream")
+
+// ErrClosed is returned by Serve and Run once the leader or follower has been closed
+//
+var ErrClosed = errors.New("replica: closed")
+
+// a record is one change to the index of the leader
+//
+type record struct {
+	sequence  uint64
+	delete    bool
+	key       string
+	keyNumber int
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func writeRecord(w io.Writer, r record) (err error) {
+	buffer := make([]byte, 0, 18+len(r.key))
+	if r.delete {
+		buffer = append(buffer, recordDelete)
+	} else {
+		buffer = append(buffer, recordInsert)
+	}
+	buffer = binary.LittleEndian.AppendUint64(buffer, r.sequence)
+	buffer = binary.LittleEndian.AppendUint64(buffer, uint64(int64(r.keyNumber)))
+	buffer = append(buffer, byte(len(r.key)))
+	buffer = append(buffer, r.key...)
+	_, err = w.Write(buffer)
+	return
+}
+
+func writeSnapshot(w io.Writer, sequence, leader uint64, saved []byte) (err error) {
+	buffer := make([]byte, 0, 25)
+	buffer = append(buffer, recordSnapshot)
+	buffer = binary.LittleEndian.AppendUint64(buffer, sequence)
+	buffer = binary.LittleEndian.AppendUint64(buffer, leader)
+	buffer = binary.LittleEndian.AppendUint64(buffer, uint64(len(saved)))
+	if _, err = w.Write(buffer); err != nil {
+		return
+	}
+	_, err = w.Write(saved)
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Leader sends the changes made to an index to its followers
+// every change to the index has to be made through Insert, Delete or Update, which hold the leader's lock, so that a
+// snapshot never sees half a change
+//
+type Leader struct {
+	mu          sync.Mutex
+	changed     *sync.Cond
+	index       *key.Index
+	leader      uint64   // tells this leader from any other, or from itself before a restart
+	sequence    uint64   // of the latest change
+	backlog     []record // the latest changes, in order
+	keep        int
+	stopObserve func()
+	listeners   []net.Listener
+	closed      bool
+}
+
+// NewLeader starts numbering the changes made to the specified index, keeping the latest "backlog" of them -- a
+// "backlog" of 0 keeps DefaultBacklog
+//
+func NewLeader(indexStructure *key.Index, backlog int) (l *Leader) {
+	if backlog <= 0 {
+		backlog = DefaultBacklog
+	}
+	l = &Leader{index: indexStructure, keep: backlog, leader: rand.Uint64() | 1}
+	l.changed = sync.NewCond(&l.mu)
+	l.stopObserve = key.Observe(l.observe, indexStructure)
+	return
+}
+
+func (l *Leader) observe(event key.Event) {
+	// called with the lock held, by whatever changed the index
+	l.sequence++
+	if len(l.backlog) == l.keep {
+		copy(l.backlog, l.backlog[1:])
+		l.backlog = l.backlog[:len(l.backlog)-1]
+	}
+	l.backlog = append(l.backlog, record{sequence: l.sequence, key: event.Key, keyNumber: event.KeyNumber,
+		delete: event.Kind == key.DuplicateRemoved || event.Kind == key.KeyDeleted})
+	l.changed.Broadcast()
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Insert places a key and its "index-number" into the index of the leader -- see key.Insert
+//
+func (l *Leader) Insert(keyInput string, keyNumber int) {
+	l.Update(func(indexStructure *key.Index) { key.Insert(keyInput, keyNumber, indexStructure) })
+}
+
+// Delete removes a key and its "index-number" from the index of the leader -- see key.Delete
+//
+func (l *Leader) Delete(keyInput string, keyNumber int) {
+	l.Update(func(indexStructure *key.Index) { key.Delete(keyInput, keyNumber, indexStructure) })
+}
+
+// Update calls "change" with the index of the leader while holding the lock -- it is how to use ApplyBatch, a
+// Transaction, or anything else that reads or changes the index
+// only the keys and "index-numbers" added and removed are sent as changes -- weights given by SetWeight, and
+// settings such as EnableSuffixes, reach a follower only in the next snapshot it is sent
+//
+func (l *Leader) Update(change func(indexStructure *key.Index)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	change(l.index)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Serve accepts followers on "listener" until it fails or the leader is closed
+//
+func (l *Leader) Serve(listener net.Listener) (err error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		listener.Close()
+		return ErrClosed
+	}
+	l.listeners = append(l.listeners, listener)
+	l.mu.Unlock()
+	for {
+		var conn net.Conn
+		if conn, err = listener.Accept(); err != nil {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.closed {
+				err = ErrClosed
+			}
+			return
+		}
+		go l.ServeConn(conn)
+	}
+}
+
+// ServeConn sends the change stream to the follower at the other end of "conn" until either end goes away, and
+// then closes it
+//
+func (l *Leader) ServeConn(conn net.Conn) (err error) {
+	defer conn.Close()
+	hello := make([]byte, len(helloMagic)+16)
+	if _, err = io.ReadFull(conn, hello); err != nil {
+		return
+	}
+	if string(hello[:len(helloMagic)]) != helloMagic {
+		return ErrBadStream
+	}
+	leader := binary.LittleEndian.Uint64(hello[len(helloMagic):])
+	next := binary.LittleEndian.Uint64(hello[len(helloMagic)+8:]) + 1
+	//
+	// the follower sends nothing more, so a read only returns when it has gone
+	gone := false
+	go func() {
+		io.Copy(io.Discard, conn)
+		l.mu.Lock()
+		gone = true
+		l.changed.Broadcast()
+		l.mu.Unlock()
+	}()
+	//
+	w := bufio.NewWriter(conn)
+	snapshot := leader != l.leader
+	for {
+		var saved bytes.Buffer
+		var records []record
+		var sequence uint64
+		l.mu.Lock()
+		for !l.closed && !gone && !snapshot && next > l.sequence {
+			l.changed.Wait()
+		}
+		if l.closed || gone {
+			l.mu.Unlock()
+			return
+		}
+		oldest := l.sequence + 1 - uint64(len(l.backlog))
+		if snapshot || next < oldest || next > l.sequence+1 {
+			if err = key.Save(&saved, l.index); err != nil {
+				l.mu.Unlock()
+				return
+			}
+			snapshot = true
+		} else {
+			records = append(records, l.backlog[next-oldest:]...)
+		}
+		sequence = l.sequence
+		l.mu.Unlock()
+		//
+		if snapshot {
+			err = writeSnapshot(w, sequence, l.leader, saved.Bytes())
+			snapshot = false
+		}
+		for _, r := range records {
+			if err == nil {
+				err = writeRecord(w, r)
+			}
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return
+		}
+		next = sequence + 1
+	}
+}
+
+// Close stops the leader -- its listeners are closed, its followers are disconnected and it stops numbering
+// changes to the index
+//
+func (l *Leader) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	l.stopObserve()
+	for _, listener := range l.listeners {
+		listener.Close()
+	}
+	l.changed.Broadcast()
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Follower applies the change stream of a leader to its own index
+// the index should only be read through View or Search while the follower is running
+//
+type Follower struct {
+	mu       sync.RWMutex
+	index    *key.Index
+	leader   uint64 // the leader the index is a copy of, 0 before the first snapshot
+	sequence uint64 // of the last change applied
+	conn     net.Conn
+	closed   bool
+	//
+	maxSnapshot int // longest saved index taken, in bytes
+}
+
+// NewFollower makes a follower that keeps the specified index a copy of the leader's -- anything already in it is
+// replaced by the first snapshot
+// a snapshot longer than "maxSnapshot" bytes ends the stream with ErrBadStream -- a "maxSnapshot" of 0 takes
+// DefaultMaxSnapshot
+//
+func NewFollower(indexStructure *key.Index, maxSnapshot int) *Follower {
+	if maxSnapshot <= 0 {
+		maxSnapshot = DefaultMaxSnapshot
+	}
+	return &Follower{index: indexStructure, maxSnapshot: maxSnapshot}
+}
+
+// View calls "read" with the index of the follower while no change is being applied to it
+//
+func (f *Follower) View(read func(indexStructure *key.Index)) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	read(f.index)
+}
+
+// Search looks a key up in the index of the follower -- see key.Search
+//
+func (f *Follower) Search(keyInput string, searchPrecisely bool) (matchFound bool, indexes []int) {
+	f.View(func(indexStructure *key.Index) {
+		matchFound, indexes = key.Search(keyInput, searchPrecisely, indexStructure)
+	})
+	return
+}
+
+// Sequence returns the number of the last change of the leader applied to the index of the follower, 0 if none has
+//
+func (f *Follower) Sequence() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.sequence
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Follow applies the change stream of the leader at the other end of "conn" until the connection fails, the stream
+// goes wrong or the follower is closed, and then closes it -- it can be called again with a new connection to carry
+// on from the last change applied
+//
+func (f *Follower) Follow(conn net.Conn) (err error) {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		conn.Close()
+		return ErrClosed
+	}
+	f.conn = conn
+	hello := make([]byte, 0, len(helloMagic)+16)
+	hello = append(hello, helloMagic...)
+	hello = binary.LittleEndian.AppendUint64(hello, f.leader)
+	hello = binary.LittleEndian.AppendUint64(hello, f.sequence)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.conn = nil
+		if f.closed {
+			err = ErrClosed
+		}
+		f.mu.Unlock()
+		conn.Close()
+	}()
+	if _, err = conn.Write(hello); err != nil {
+		return
+	}
+	//
+	r := bufio.NewReader(conn)
+	header := make([]byte, 17)
+	for {
+		if _, err = io.ReadFull(r, header[:9]); err != nil {
+			return
+		}
+		sequence := binary.LittleEndian.Uint64(header[1:])
+		switch header[0] {
+		case recordSnapshot:
+			if err = f.loadSnapshot(r, sequence); err != nil {
+				return
+			}
+		case recordInsert, recordDelete:
+			if _, err = io.ReadFull(r, header[9:17]); err != nil {
+				return
+			}
+			keyNumber := int(int64(binary.LittleEndian.Uint64(header[9:])))
+			var length byte
+			if length, err = r.ReadByte(); err != nil {
+				return
+			}
+			keyField := make([]byte, length)
+			if _, err = io.ReadFull(r, keyField); err != nil {
+				return
+			}
+			if err = f.apply(record{sequence: sequence, delete: header[0] == recordDelete, key: string(keyField),
+				keyNumber: keyNumber}); err != nil {
+				return
+			}
+		default:
+			return ErrBadStream
+		}
+	}
+}
+
+func (f *Follower) loadSnapshot(r io.Reader, sequence uint64) (err error) {
+	fields := make([]byte, 16)
+	if _, err = io.ReadFull(r, fields); err != nil {
+		return
+	}
+	leader := binary.LittleEndian.Uint64(fields)
+	length := binary.LittleEndian.Uint64(fields[8:])
+	if length > uint64(f.maxSnapshot) {
+		return ErrBadStream
+	}
+	// read into a buffer that grows as the bytes arrive, so a length that is a lie costs nothing
+	var saved bytes.Buffer
+	if _, err = io.CopyN(&saved, r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err = key.Load(&saved, f.index); err != nil {
+		return
+	}
+	f.leader, f.sequence = leader, sequence
+	return
+}
+
+func (f *Follower) apply(r record) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.leader == 0 || r.sequence != f.sequence+1 {
+		return ErrBadStream
+	}
+	if r.delete {
+		key.Delete(r.key, r.keyNumber, f.index)
+	} else {
+		key.Insert(r.key, r.keyNumber, f.index)
+	}
+	f.sequence = r.sequence
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Run dials the leader with "dial" and follows it, dialling again after "retry" whenever the connection is lost,
+// until the follower is closed
+//
+func (f *Follower) Run(dial func() (net.Conn, error), retry time.Duration) error {
+	for {
+		conn, err := dial()
+		if err == nil {
+			err = f.Follow(conn)
+		}
+		if err == ErrClosed {
+			return err
+		}
+		f.mu.RLock()
+		closed := f.closed
+		f.mu.RUnlock()
+		if closed {
+			return ErrClosed
+		}
+		time.Sleep(retry)
+	}
+}
+
+// Close stops the follower, dropping its connection to the leader -- the index keeps the changes applied so far
+//
+func (f *Follower) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	if f.conn != nil {
+		f.conn.Close()
+	}
+}
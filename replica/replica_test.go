@@ -0,0 +1,343 @@
+package replica
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apwoodhouse/key"
+)
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !done(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func contents(indexStructure *key.Index) string {
+	// every "index-number" in the index, in the order of their keys, with the key of each
+	var out []string
+	for _, prefix := range []string{"a", "b", "k"} {
+		for x := 0; x < 100; x++ {
+			keyField := fmt.Sprint(prefix, x)
+			if _, indexes := key.Search(keyField, true, indexStructure); len(indexes) > 0 {
+				out = append(out, fmt.Sprint(keyField, indexes))
+			}
+		}
+	}
+	return fmt.Sprint(out)
+}
+
+func caughtUp(t *testing.T, l *Leader, f *Follower) {
+	// waits until the follower has applied every change made by the leader, and checks their indexes agree
+	t.Helper()
+	var sequence uint64
+	var want string
+	l.Update(func(indexStructure *key.Index) { sequence, want = l.sequence, contents(indexStructure) })
+	waitFor(t, fmt.Sprint("change ", sequence), func() bool { return f.Sequence() == sequence })
+	var got string
+	f.View(func(indexStructure *key.Index) { got = contents(indexStructure) })
+	if got != want {
+		t.Fatalf("follower has %s, want %s", got, want)
+	}
+}
+
+// recordingConn keeps the first byte the follower reads from the leader, to tell a snapshot from a record
+//
+type recordingConn struct {
+	net.Conn
+	mu    sync.Mutex
+	first []byte
+}
+
+func (c *recordingConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.mu.Lock()
+	if c.first == nil && n > 0 {
+		c.first = []byte{b[0]}
+	}
+	c.mu.Unlock()
+	return
+}
+
+func (c *recordingConn) firstByte() byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.first == nil {
+		return 0
+	}
+	return c.first[0]
+}
+
+func connect(l *Leader, f *Follower) (conn *recordingConn, stop func() error) {
+	// joins the leader and the follower with a net.Pipe -- "stop" drops the connection and returns what Follow did
+	leaderEnd, followerEnd := net.Pipe()
+	conn = &recordingConn{Conn: followerEnd}
+	followed := make(chan error, 1)
+	go l.ServeConn(leaderEnd)
+	go func() { followed <- f.Follow(conn) }()
+	stop = func() error {
+		followerEnd.Close()
+		return <-followed
+	}
+	return
+}
+
+func fakeLeader(t *testing.T, stream []byte, maxSnapshot int) (err error) {
+	// has a follower follow a leader that sends "stream" and then hangs up
+	t.Helper()
+	leaderEnd, followerEnd := net.Pipe()
+	go func() {
+		defer leaderEnd.Close()
+		if _, err := io.ReadFull(leaderEnd, make([]byte, len(helloMagic)+16)); err != nil {
+			return
+		}
+		leaderEnd.Write(stream)
+	}()
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	return NewFollower(&indexStructure, maxSnapshot).Follow(followerEnd)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestSnapshotAndRecords(t *testing.T) {
+	// a new follower is sent a snapshot of what the leader already has, and then each change as it is made
+	var leaderIndex, followerIndex key.Index
+	key.Initialise(&leaderIndex)
+	key.InitialiseLayout(&followerIndex, key.CompactLayout)
+	for x := 0; x < 50; x++ {
+		key.Insert(fmt.Sprint("a", x%7), x, &leaderIndex)
+	}
+	key.Insert("k1", 5, &followerIndex)
+	l, f := NewLeader(&leaderIndex, 0), NewFollower(&followerIndex, 0)
+	defer l.Close()
+	defer f.Close()
+	conn, stop := connect(l, f)
+	waitFor(t, "the snapshot", func() bool {
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		return f.leader != 0
+	})
+	if first := conn.firstByte(); first != recordSnapshot {
+		t.Fatalf("first record was %q, want a snapshot", first)
+	}
+	if _, indexes := f.Search("k1", true); len(indexes) > 0 {
+		t.Fatalf("what the follower had before the snapshot is still there: %v", indexes)
+	}
+	for x := 0; x < 50; x++ {
+		l.Insert(fmt.Sprint("b", x%9), x)
+		if x%3 == 0 {
+			l.Delete(fmt.Sprint("a", x%7), x)
+		}
+	}
+	l.Update(func(indexStructure *key.Index) {
+		key.ApplyBatch([]key.Op{{Key: "k1", KeyNumber: 1}, {Delete: true, Key: "b1", KeyNumber: 1}}, indexStructure)
+	})
+	caughtUp(t, l, f)
+	if matchFound, indexes := f.Search("k1", true); !matchFound || fmt.Sprint(indexes) != "[1]" {
+		t.Errorf("Search on the follower gave %v %v", matchFound, indexes)
+	}
+	stop()
+}
+
+func TestResume(t *testing.T) {
+	// a follower that comes back within the backlog of the leader is sent only the changes it missed, and one that
+	// comes back beyond it, or to a different leader, is sent a snapshot
+	var leaderIndex, followerIndex key.Index
+	key.Initialise(&leaderIndex)
+	key.Initialise(&followerIndex)
+	l, f := NewLeader(&leaderIndex, 10), NewFollower(&followerIndex, 0)
+	defer f.Close()
+	_, stop := connect(l, f)
+	for x := 0; x < 20; x++ {
+		l.Insert(fmt.Sprint("a", x), x)
+	}
+	caughtUp(t, l, f)
+	stop()
+	//
+	tests := []struct {
+		name    string
+		changes int
+		want    byte
+	}{
+		{name: "within the backlog", changes: 10, want: recordInsert},
+		{name: "nothing missed", changes: 0, want: recordInsert},
+		{name: "beyond the backlog", changes: 11, want: recordSnapshot},
+	}
+	next := 20
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for x := 0; x < test.changes; x++ {
+				l.Insert(fmt.Sprint("b", next), next)
+				next++
+			}
+			conn, stop := connect(l, f)
+			defer stop()
+			if test.changes == 0 {
+				l.Insert(fmt.Sprint("b", next), next)
+				next++
+			}
+			caughtUp(t, l, f)
+			if first := conn.firstByte(); first != test.want {
+				t.Errorf("first record was %q, want %q", first, test.want)
+			}
+		})
+	}
+	//
+	// a leader started again over the same index is another leader
+	l.Close()
+	l = NewLeader(&leaderIndex, 10)
+	defer l.Close()
+	l.Delete("a1", 1)
+	conn, stop := connect(l, f)
+	defer stop()
+	caughtUp(t, l, f)
+	if first := conn.firstByte(); first != recordSnapshot {
+		t.Errorf("first record from a new leader was %q, want a snapshot", first)
+	}
+}
+
+func TestBadStream(t *testing.T) {
+	var leaderIndex key.Index
+	key.Initialise(&leaderIndex)
+	key.Insert("a1", 1, &leaderIndex)
+	var saved bytes.Buffer
+	if err := key.Save(&saved, &leaderIndex); err != nil {
+		t.Fatal(err)
+	}
+	stream := func(write func(w io.Writer)) []byte {
+		var b bytes.Buffer
+		write(&b)
+		return b.Bytes()
+	}
+	tests := []struct {
+		name        string
+		stream      []byte
+		maxSnapshot int
+		want        error
+	}{
+		{name: "record before a snapshot", stream: stream(func(w io.Writer) {
+			writeRecord(w, record{sequence: 1, key: "a2", keyNumber: 2})
+		}), want: ErrBadStream},
+		{name: "out of sequence", stream: stream(func(w io.Writer) {
+			writeSnapshot(w, 5, 3, saved.Bytes())
+			writeRecord(w, record{sequence: 6, key: "a2", keyNumber: 2})
+			writeRecord(w, record{sequence: 8, key: "a3", keyNumber: 3})
+		}), want: ErrBadStream},
+		{name: "unknown record", stream: stream(func(w io.Writer) {
+			writeSnapshot(w, 5, 3, saved.Bytes())
+			w.Write([]byte("X12345678"))
+		}), want: ErrBadStream},
+		{name: "snapshot too long", stream: stream(func(w io.Writer) {
+			w.Write([]byte{recordSnapshot, 1, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 4, 0, 0, 0, 0})
+		}), want: ErrBadStream},
+		// a follower given a smaller limit takes a snapshot that fits it exactly, and not one a byte longer
+		{name: "snapshot at the limit", stream: stream(func(w io.Writer) {
+			writeSnapshot(w, 5, 3, saved.Bytes())
+		}), maxSnapshot: saved.Len(), want: io.EOF},
+		{name: "snapshot over the limit", stream: stream(func(w io.Writer) {
+			writeSnapshot(w, 5, 3, saved.Bytes())
+		}), maxSnapshot: saved.Len() - 1, want: ErrBadStream},
+		{name: "snapshot cut short", stream: stream(func(w io.Writer) {
+			w.Write([]byte{recordSnapshot, 1, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0})
+			w.Write(saved.Bytes()[:3])
+		}), want: io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := fakeLeader(t, test.stream, test.maxSnapshot); !errors.Is(err, test.want) {
+				t.Errorf("Follow gave %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestBadHello(t *testing.T) {
+	var indexStructure key.Index
+	key.Initialise(&indexStructure)
+	l := NewLeader(&indexStructure, 0)
+	defer l.Close()
+	leaderEnd, followerEnd := net.Pipe()
+	defer followerEnd.Close()
+	served := make(chan error, 1)
+	go func() { served <- l.ServeConn(leaderEnd) }()
+	followerEnd.Write([]byte("KEYREPL0" + "0123456789abcdef"))
+	if err := <-served; !errors.Is(err, ErrBadStream) {
+		t.Errorf("ServeConn gave %v, want %v", err, ErrBadStream)
+	}
+}
+
+func TestServeAndRun(t *testing.T) {
+	// followers dial a leader over loopback, catch up again after losing their connections, and stop when closed
+	var leaderIndex key.Index
+	key.Initialise(&leaderIndex)
+	l := NewLeader(&leaderIndex, 20)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- l.Serve(listener) }()
+	//
+	var mu sync.Mutex
+	var conns []net.Conn
+	dial := func() (net.Conn, error) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+		return conn, err
+	}
+	var followers []*Follower
+	ran := make(chan error, 2)
+	for _, layout := range []key.Layout{key.WideLayout, key.CompactLayout} {
+		var followerIndex key.Index
+		key.InitialiseLayout(&followerIndex, layout)
+		f := NewFollower(&followerIndex, 0)
+		followers = append(followers, f)
+		go func() { ran <- f.Run(dial, time.Millisecond) }()
+	}
+	for round := 0; round < 5; round++ {
+		for x := 0; x < 30; x++ {
+			keyField := fmt.Sprint("k", (round*7+x)%40)
+			if x%4 == 3 {
+				l.Delete(keyField, x%3)
+			} else {
+				l.Insert(keyField, x%3)
+			}
+		}
+		for _, f := range followers {
+			caughtUp(t, l, f)
+		}
+		mu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		conns = nil
+		mu.Unlock()
+	}
+	//
+	for _, f := range followers {
+		f.Close()
+		if err := <-ran; err != ErrClosed {
+			t.Errorf("Run gave %v, want %v", err, ErrClosed)
+		}
+	}
+	l.Close()
+	if err := <-served; err != ErrClosed {
+		t.Errorf("Serve gave %v, want %v", err, ErrClosed)
+	}
+}
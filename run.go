@@ -0,0 +1,175 @@
+package key
+
+// a run of characters that no key ends in and no branch leaves is held in a single 'P' node rather than a chain of
+// 'X' nodes -- the node's key is the first character of the run, its left pointer is the run's slot in "runs", and
+// its right pointer is the child below the last character
+// Insert and Delete open every run on the way down to the key into 'X' nodes, as far as the key follows it, before
+// they change anything, and close the chains on that path back into runs afterwards, so they only ever meet a 'P'
+// node whose first character is not the key's
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) characters(node indexNode) string {
+	// the characters a node stands for
+	if node.status == 'P' {
+		return indexStructure.runs[node.leftPointer]
+	}
+	return string([]byte{node.key})
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) newRun(run string) (slot int) {
+	if len(indexStructure.freeRuns) > 0 {
+		slot = indexStructure.freeRuns[len(indexStructure.freeRuns)-1]
+		indexStructure.freeRuns = indexStructure.freeRuns[:len(indexStructure.freeRuns)-1]
+		indexStructure.runs[slot] = run
+		return
+	}
+	indexStructure.runs = append(indexStructure.runs, run)
+	return len(indexStructure.runs) - 1
+}
+
+func (indexStructure *Index) dropRun(slot int) {
+	indexStructure.runs[slot] = ""
+	indexStructure.freeRuns = append(indexStructure.freeRuns, slot)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (indexStructure *Index) open(x, count int) {
+	// turns the first "count" characters of the 'P' node at "x" back into a chain of 'X' nodes, the first of them at
+	// "x" -- the rest of the run, if there is any, is left in one node below them
+	node := indexStructure.nodeAt(x)
+	run := indexStructure.runs[node.leftPointer]
+	count = min(count, len(run))
+	child := node.rightPointer
+	if len(run)-count >= 2 {
+		indexStructure.runs[node.leftPointer] = run[count:]
+		child = indexStructure.allocate(indexNode{status: 'P', leftPointer: node.leftPointer, key: run[count],
+			rightPointer: child}, x)
+	} else {
+		indexStructure.dropRun(node.leftPointer)
+		if count < len(run) {
+			child = indexStructure.allocate(indexNode{status: 'X', leftPointer: nullIndexPointer, key: run[count],
+				rightPointer: child}, x)
+		}
+	}
+	if count < len(run) && indexStructure.weights != nil {
+		indexStructure.weights.copy(child, x)
+	}
+	for i := count - 1; i > 0; i-- {
+		child = indexStructure.allocate(indexNode{status: 'X', leftPointer: nullIndexPointer, key: run[i],
+			rightPointer: child}, x)
+		if indexStructure.weights != nil {
+			indexStructure.weights.copy(child, x)
+		}
+	}
+	indexStructure.setNode(x, indexNode{status: 'X', leftPointer: nullIndexPointer, key: run[0], rightPointer: child})
+}
+
+func (indexStructure *Index) close(x int) {
+	// gathers the 'X' and 'P' nodes chained below "x", which is one of them, into a single 'P' node at "x"
+	node := indexStructure.nodeAt(x)
+	run := indexStructure.characters(node)
+	child := node.rightPointer
+	for child != nullIndexPointer {
+		below := indexStructure.nodeAt(child)
+		if below.status != 'X' && below.status != 'P' {
+			break
+		}
+		if len(run)+len(indexStructure.characters(below)) > maxKeyLength {
+			break
+		}
+		run += indexStructure.characters(below)
+		if below.status == 'P' {
+			indexStructure.dropRun(below.leftPointer)
+		}
+		indexStructure.free(child)
+		child = below.rightPointer
+	}
+	if len(run) < 2 {
+		return
+	}
+	if node.status == 'P' {
+		indexStructure.runs[node.leftPointer] = run
+	} else {
+		node.status, node.leftPointer = 'P', indexStructure.newRun(run)
+	}
+	node.rightPointer = child
+	indexStructure.setNode(x, node)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func matching(run, keyField string) (count int) {
+	// the number of characters at the start of "run" that "keyField" starts with too
+	for count < len(run) && count < len(keyField) && run[count] == keyField[count] {
+		count++
+	}
+	return
+}
+
+func (indexStructure *Index) openPath(keyField string) {
+	// opens every run on the way down to "keyField", as far as the key matches
+	keyPointer := indexStructure.indexRoot
+	for i := 0; keyPointer != nullIndexPointer && i < len(keyField); {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			if keyField[i] <= node.key {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		if keyField[i] != node.key || node.status == 'S' || node.status == 'L' {
+			return
+		}
+		if node.status == 'P' {
+			indexStructure.open(keyPointer, matching(indexStructure.runs[node.leftPointer], keyField[i:]))
+		}
+		keyPointer = indexStructure.nodeAt(keyPointer).rightPointer
+		i++
+	}
+}
+
+func (indexStructure *Index) closePath(keyField string, from descent) {
+	// closes every chain of 'X' nodes on the way down to "keyField" into runs, as far as the key matches, starting at
+	// the level "from"
+	keyPointer := indexStructure.levelRoot(from.levelPointer)
+	for i := from.i; keyPointer != nullIndexPointer && i < len(keyField); {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			if keyField[i] <= node.key {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		if keyField[i] != node.key || node.status == 'S' || node.status == 'L' {
+			return
+		}
+		if node.status == 'X' || node.status == 'P' {
+			indexStructure.close(keyPointer)
+			node = indexStructure.nodeAt(keyPointer)
+			run := indexStructure.characters(node)
+			if len(keyField)-i < len(run) || keyField[i:i+len(run)] != run {
+				return
+			}
+			i += len(run)
+		} else {
+			i++
+		}
+		keyPointer = node.rightPointer
+	}
+}
@@ -0,0 +1,167 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func unclosedRun(indexStructure *Index) int {
+	// the first 'X' or 'P' node whose child could have been joined to it in a run, or null -- duplicate branches are
+	// left out as they never hold runs
+	var find func(keyPointer int) int
+	find = func(keyPointer int) int {
+		node := indexStructure.nodeAt(keyPointer)
+		switch node.status {
+		case 'D':
+			if x := find(node.leftPointer); x != nullIndexPointer {
+				return x
+			}
+			return find(node.rightPointer)
+		case 'X', 'P':
+			child := indexStructure.nodeAt(node.rightPointer)
+			if (child.status == 'X' || child.status == 'P') &&
+				len(indexStructure.characters(node))+len(indexStructure.characters(child)) <= maxKeyLength {
+				return keyPointer
+			}
+			return find(node.rightPointer)
+		case 'R', 'K':
+			return find(node.rightPointer)
+		}
+		return nullIndexPointer
+	}
+	if indexStructure.indexRoot == nullIndexPointer {
+		return nullIndexPointer
+	}
+	return find(indexStructure.indexRoot)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func heldRuns(indexStructure *Index) (runs []string) {
+	// the runs held by the 'P' nodes of the tree, in order -- duplicate branches never hold runs
+	var find func(keyPointer int)
+	find = func(keyPointer int) {
+		node := indexStructure.nodeAt(keyPointer)
+		switch node.status {
+		case 'D':
+			find(node.leftPointer)
+			find(node.rightPointer)
+		case 'P':
+			runs = append(runs, indexStructure.runs[node.leftPointer])
+			find(node.rightPointer)
+		case 'X', 'R', 'K':
+			find(node.rightPointer)
+		}
+	}
+	if indexStructure.indexRoot != nullIndexPointer {
+		find(indexStructure.indexRoot)
+	}
+	sort.Strings(runs)
+	return
+}
+
+func checkRuns(t *testing.T, indexStructure *Index, held map[string]int) {
+	// every key held is found with its number, every chain that could be a run is one, and every run slot in use
+	// belongs to a 'P' node
+	t.Helper()
+	for keyInput, keyNumber := range held {
+		if _, indexes := Search(keyInput, true, indexStructure); fmt.Sprint(indexes) != fmt.Sprint([]int{keyNumber}) {
+			t.Fatalf("Search(%q) gave %v, want [%d]", keyInput, indexes, keyNumber)
+		}
+	}
+	if x := unclosedRun(indexStructure); x != nullIndexPointer {
+		t.Fatalf("node %d heads a chain that is not a run", x)
+	}
+	if inUse := len(indexStructure.runs) - len(indexStructure.freeRuns); inUse != len(heldRuns(indexStructure)) {
+		t.Fatalf("%d run slots in use for %d runs", inUse, len(heldRuns(indexStructure)))
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestRuns(t *testing.T) {
+	tests := []struct {
+		name       string
+		insert     []string
+		delete     []string
+		wantRuns   []string
+		wantActive int
+	}{
+		{name: "one key", insert: []string{"abcdefgh"}, wantRuns: []string{"abcdefg"}, wantActive: 2},
+		{name: "two characters", insert: []string{"ab"}, wantActive: 2},
+		{name: "longest key", insert: []string{strings.Repeat("x", 40)}, wantRuns: []string{strings.Repeat("x", 31)},
+			wantActive: 2},
+		{name: "split", insert: []string{"abcdefgh", "abcdxyz"}, wantRuns: []string{"abcd", "efg", "xy"},
+			wantActive: 6},
+		{name: "merged on delete", insert: []string{"abcdefgh", "abcdxyz"}, delete: []string{"abcdxyz"},
+			wantRuns: []string{"abcdefg"}, wantActive: 2},
+		{name: "key inside a run", insert: []string{"abcdefgh", "abcd"}, wantRuns: []string{"abc", "efg"},
+			wantActive: 4},
+		{name: "key inside a run deleted", insert: []string{"abcdefgh", "abcd"}, delete: []string{"abcd"},
+			wantRuns: []string{"abcdefg"}, wantActive: 2},
+		{name: "urls", insert: []string{"http://example.com/a/b/c", "http://example.com/a/x"},
+			wantRuns: []string{"b/", "http://example.com/a/"}, wantActive: 5},
+		{name: "all deleted", insert: []string{"abcdefgh", "abcdxyz"}, delete: []string{"abcdefgh", "abcdxyz"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var indexStructure Index
+			Initialise(&indexStructure)
+			held := map[string]int{}
+			for x, keyInput := range test.insert {
+				Insert(keyInput, x, &indexStructure)
+				held[keyInput] = x
+			}
+			for _, keyInput := range test.delete {
+				Delete(keyInput, held[keyInput], &indexStructure)
+				delete(held, keyInput)
+				if matchFound, _ := Search(keyInput, true, &indexStructure); matchFound {
+					t.Errorf("Search(%q) found the deleted key", keyInput)
+				}
+			}
+			checkRuns(t, &indexStructure, held)
+			if runs := heldRuns(&indexStructure); fmt.Sprint(runs) != fmt.Sprint(test.wantRuns) {
+				t.Errorf("runs %q, want %q", runs, test.wantRuns)
+			}
+			if active := Statistics(&indexStructure).Active; active != test.wantActive {
+				t.Errorf("%d active nodes, want %d", active, test.wantActive)
+			}
+		})
+	}
+}
+
+func TestRunsKeptClosed(t *testing.T) {
+	// paths sharing long prefixes are inserted and deleted in several orders, in either layout, and the runs are
+	// opened and closed again around each change
+	keys := []string{"/usr/local/lib/go/src/net/http", "/usr/local/lib/go/src/net", "/usr/local/lib/go/pkg",
+		"/usr/local/bin", "/usr/lib/x86_64-linux-gnu/libc.so.6", "/usr/lib/x86_64-linux-gnu/libm.so.6", "/usr",
+		"/var/log/syslog", "/var/log/syslog.1", "/var/lib/dpkg/status", "/etc/ssl/certs/ca-certificates.crt"}
+	for _, layout := range benchmarkLayouts {
+		for seed := int64(0); seed < 20; seed++ {
+			r := rand.New(rand.NewSource(seed))
+			var indexStructure Index
+			InitialiseLayout(&indexStructure, layout.layout)
+			held := map[string]int{}
+			for _, x := range r.Perm(len(keys)) {
+				Insert(keys[x], x, &indexStructure)
+				held[trimKey(keys[x])] = x
+				checkRuns(t, &indexStructure, held)
+			}
+			for _, x := range r.Perm(len(keys)) {
+				Delete(keys[x], x, &indexStructure)
+				delete(held, trimKey(keys[x]))
+				checkRuns(t, &indexStructure, held)
+			}
+			if indexStructure.indexRoot != nullIndexPointer || len(heldRuns(&indexStructure)) != 0 {
+				t.Errorf("%s seed %d: the index is not empty once every key is deleted", layout.name, seed)
+			}
+		}
+	}
+}
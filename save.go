@@ -0,0 +1,269 @@
+package key
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	saveMagic   = "KEYINDEX"
+	saveVersion = 1
+)
+
+const (
+	saveSuffixes = 1 << iota // the index keeps the tails of its keys
+	saveWeights              // the index keeps weights, which follow
+)
+
+// ErrNotIndex is returned by Load when the input does not hold a saved index structure
+//
+var ErrNotIndex = errors.New("key: not a saved index")
+
+// ErrTooLarge is returned by Load when a saved index holds numbers too large for the compact layout of the index
+// structure being loaded into
+//
+var ErrTooLarge = errors.New("key: saved index too large for the compact layout")
+
+// ErrDamaged is returned by Load, together with the first fault found, when the saved index structure does not pass
+// Verify
+//
+var ErrDamaged = errors.New("key: saved index is damaged")
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Save writes the specified index structure to "w" -- the node array and the runs of characters are written exactly
+// as they are, free lists and all, so that Load gives back an identical structure
+// the weights set by SetWeight are written too, along with whether EnableSuffixes was called -- the tails themselves
+// are not written, as Load can build them again from the keys
+//
+func Save(w io.Writer, indexStructure *Index) (err error) {
+	b := bufio.NewWriter(w)
+	header := make([]byte, 0, 36)
+	header = append(header, saveMagic...)
+	header = binary.LittleEndian.AppendUint32(header, saveVersion)
+	header = binary.LittleEndian.AppendUint64(header, uint64(int64(indexStructure.indexRoot)))
+	header = binary.LittleEndian.AppendUint64(header, uint64(int64(indexStructure.deletedRoot)))
+	header = binary.LittleEndian.AppendUint64(header, uint64(indexStructure.nodeCount()))
+	if _, err = b.Write(header); err != nil {
+		return
+	}
+	record := make([]byte, 18)
+	for x := 0; x < indexStructure.nodeCount(); x++ {
+		node := indexStructure.nodeAt(x)
+		record[0] = node.status
+		record[1] = node.key
+		binary.LittleEndian.PutUint64(record[2:], uint64(int64(node.leftPointer)))
+		binary.LittleEndian.PutUint64(record[10:], uint64(int64(node.rightPointer)))
+		if _, err = b.Write(record); err != nil {
+			return
+		}
+	}
+	//
+	runs := binary.LittleEndian.AppendUint64(nil, uint64(len(indexStructure.runs)))
+	for _, run := range indexStructure.runs {
+		runs = append(runs, byte(len(run)))
+		runs = append(runs, run...)
+	}
+	runs = binary.LittleEndian.AppendUint64(runs, uint64(len(indexStructure.freeRuns)))
+	for _, slot := range indexStructure.freeRuns {
+		runs = binary.LittleEndian.AppendUint64(runs, uint64(slot))
+	}
+	if _, err = b.Write(runs); err != nil {
+		return
+	}
+	if _, err = b.Write(saveExtras(indexStructure)); err != nil {
+		return
+	}
+	err = b.Flush()
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Load replaces the specified index structure with one read from "r" that was written by Save, keeping its layout
+// and allocation
+// the tails kept for SearchSuffix and SearchContains are built again and the weights given back if they were kept
+// when the index was saved, whatever the structure being loaded into kept
+// the structure read is checked by Verify before anything walks it, so a damaged file gives ErrDamaged rather than a
+// panic, and any level grown too deep in 'D' nodes is balanced as Insert would have kept it -- the structure is left
+// untouched if the input cannot be read or is damaged
+//
+func Load(r io.Reader, indexStructure *Index) (err error) {
+	b := bufio.NewReader(r)
+	header := make([]byte, 36)
+	if _, err = io.ReadFull(b, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrNotIndex
+		}
+		return
+	}
+	version := binary.LittleEndian.Uint32(header[8:])
+	if string(header[0:8]) != saveMagic || version != saveVersion {
+		err = ErrNotIndex
+		return
+	}
+	var loaded Index
+	InitialiseLayout(&loaded, IndexLayout(indexStructure))
+	loaded.indexRoot = int(int64(binary.LittleEndian.Uint64(header[12:])))
+	loaded.deletedRoot = int(int64(binary.LittleEndian.Uint64(header[20:])))
+	count := binary.LittleEndian.Uint64(header[28:])
+	//
+	record := make([]byte, 18)
+	for i := uint64(0); i < count; i++ {
+		if _, err = io.ReadFull(b, record); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		node := indexNode{
+			status:       record[0],
+			key:          record[1],
+			leftPointer:  int(int64(binary.LittleEndian.Uint64(record[2:]))),
+			rightPointer: int(int64(binary.LittleEndian.Uint64(record[10:]))),
+		}
+		if !loaded.holdsNumber(node.leftPointer) || !loaded.holdsNumber(node.rightPointer) {
+			err = ErrTooLarge
+			return
+		}
+		loaded.appendNode(node)
+	}
+	if err = loadRuns(b, &loaded); err != nil {
+		return
+	}
+	if valid, faults := Verify(&loaded); !valid {
+		err = fmt.Errorf("%w: %s (%d faults)", ErrDamaged, faults[0], len(faults))
+		return
+	}
+	if loaded.indexRoot != nullIndexPointer {
+		loaded.balanceAll(nullIndexPointer)
+	}
+	//
+	allocation, trim := IndexAllocation(indexStructure)
+	SetAllocation(&loaded, allocation, trim)
+	if err = loadExtras(b, &loaded); err != nil {
+		return
+	}
+	loaded.observers = indexStructure.observers
+	*indexStructure = loaded
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func loadRuns(b *bufio.Reader, loaded *Index) (err error) {
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+	count := make([]byte, 8)
+	if _, err = io.ReadFull(b, count); err != nil {
+		return
+	}
+	for i := binary.LittleEndian.Uint64(count); i > 0; i-- {
+		var length byte
+		if length, err = b.ReadByte(); err != nil {
+			return
+		}
+		run := make([]byte, length)
+		if _, err = io.ReadFull(b, run); err != nil {
+			return
+		}
+		loaded.runs = append(loaded.runs, string(run))
+	}
+	if _, err = io.ReadFull(b, count); err != nil {
+		return
+	}
+	for i := binary.LittleEndian.Uint64(count); i > 0; i-- {
+		if _, err = io.ReadFull(b, count); err != nil {
+			return
+		}
+		loaded.freeRuns = append(loaded.freeRuns, int(binary.LittleEndian.Uint64(count)))
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func saveExtras(indexStructure *Index) (extras []byte) {
+	// a byte of flags, then for weights a count and each entry as its key, number and weight
+	var flags byte
+	if indexStructure.suffixes != nil {
+		flags |= saveSuffixes
+	}
+	if indexStructure.weights == nil {
+		return append(extras, flags)
+	}
+	extras = append(extras, flags|saveWeights)
+	entries := make([]keyEntry, 0, len(indexStructure.weights.weight))
+	for entry := range indexStructure.weights.weight {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	extras = binary.LittleEndian.AppendUint64(extras, uint64(len(entries)))
+	for _, entry := range entries {
+		extras = append(extras, byte(len(entry.key)))
+		extras = append(extras, entry.key...)
+		extras = binary.LittleEndian.AppendUint64(extras, uint64(int64(entry.keyNumber)))
+		extras = binary.LittleEndian.AppendUint64(extras, uint64(int64(indexStructure.weights.weight[entry])))
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func loadExtras(b *bufio.Reader, loaded *Index) (err error) {
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+	flags, err := b.ReadByte()
+	if err != nil {
+		return
+	}
+	if flags&saveWeights != 0 {
+		number := make([]byte, 8)
+		if _, err = io.ReadFull(b, number); err != nil {
+			return
+		}
+		weight := make(map[keyEntry]int)
+		for i := binary.LittleEndian.Uint64(number); i > 0; i-- {
+			var length byte
+			if length, err = b.ReadByte(); err != nil {
+				return
+			}
+			keyField := make([]byte, length)
+			if _, err = io.ReadFull(b, keyField); err != nil {
+				return
+			}
+			if _, err = io.ReadFull(b, number); err != nil {
+				return
+			}
+			entry := keyEntry{key: string(keyField), keyNumber: int(int64(binary.LittleEndian.Uint64(number)))}
+			if _, err = io.ReadFull(b, number); err != nil {
+				return
+			}
+			weight[entry] = int(int64(binary.LittleEndian.Uint64(number)))
+		}
+		weigh(weight, loaded)
+	}
+	if flags&saveSuffixes != 0 {
+		EnableSuffixes(loaded)
+	}
+	return
+}
@@ -0,0 +1,220 @@
+package key
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func nodesText(t *testing.T, indexStructure *Index) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := WriteNodes(&b, indexStructure); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestSaveLoad(t *testing.T) {
+	// Load gives back exactly the node array, free list and runs that were saved, whatever layout it loads into --
+	// loaded under another allocation the free list is rebuilt, and only the entries are the same
+	for _, c := range testConfigs {
+		for _, into := range testConfigs {
+			t.Run(c.name+"/"+into.name, func(t *testing.T) {
+				for seed := int64(0); seed < 5; seed++ {
+					indexStructure, m := c.index(), testModel{}
+					churn(t, indexStructure, m, rand.New(rand.NewSource(seed)), "ab/.", 12, 100)
+					var b bytes.Buffer
+					if err := Save(&b, indexStructure); err != nil {
+						t.Fatal(err)
+					}
+					loaded := into.index()
+					if err := Load(&b, loaded); err != nil {
+						t.Fatal(err)
+					}
+					if c.allocation == into.allocation && c.trim == into.trim &&
+						nodesText(t, loaded) != nodesText(t, indexStructure) {
+						t.Fatalf("seed %d: loaded nodes differ from those saved", seed)
+					}
+					if allocation, trim := IndexAllocation(loaded); IndexLayout(loaded) != into.layout ||
+						allocation != into.allocation || trim != into.trim {
+						t.Fatalf("seed %d: loaded into %v %v %v", seed, IndexLayout(loaded), allocation, trim)
+					}
+					checkIndex(t, loaded, m)
+					churn(t, loaded, m, rand.New(rand.NewSource(seed)), "ab/.", 12, 100)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var saved bytes.Buffer
+	indexStructure := testConfigs[0].index()
+	for x, keyInput := range []string{"alpha", "alps", "beta"} {
+		Insert(keyInput, x, indexStructure)
+	}
+	Insert("big", 1<<40, indexStructure)
+	if err := Save(&saved, indexStructure); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		input []byte
+		into  Layout
+		want  error
+	}{
+		{name: "empty", input: nil, into: WideLayout, want: ErrNotIndex},
+		{name: "not an index", input: []byte("KEYINDEZ and then some more bytes to fill a header"), into: WideLayout,
+			want: ErrNotIndex},
+		{name: "short header", input: saved.Bytes()[:20], into: WideLayout, want: ErrNotIndex},
+		{name: "cut short in the nodes", input: saved.Bytes()[:60], into: WideLayout, want: io.ErrUnexpectedEOF},
+		{name: "cut short at the end", input: saved.Bytes()[:saved.Len()-1], into: WideLayout,
+			want: io.ErrUnexpectedEOF},
+		{name: "too large", input: saved.Bytes(), into: CompactLayout, want: ErrTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var loaded Index
+			InitialiseLayout(&loaded, test.into)
+			Insert("kept", 7, &loaded)
+			if err := Load(bytes.NewReader(test.input), &loaded); !errors.Is(err, test.want) {
+				t.Fatalf("Load gave %v, want %v", err, test.want)
+			}
+			// a failed Load leaves the index as it was
+			checkIndex(t, &loaded, testModel{{key: "kept", keyNumber: 7}: true})
+		})
+	}
+}
+
+func TestLoadDamaged(t *testing.T) {
+	// a saved structure that does not pass Verify is refused before anything walks it
+	tests := []struct {
+		name   string
+		damage func(indexStructure *Index)
+	}{
+		{name: "pointer out of range",
+			damage: func(indexStructure *Index) { indexStructure.setRightPointer(indexStructure.indexRoot, 1<<20) }},
+		{name: "root out of range", damage: func(indexStructure *Index) { indexStructure.indexRoot = -7 }},
+		{name: "bad status",
+			damage: func(indexStructure *Index) { indexStructure.setStatus(indexStructure.indexRoot, '?') }},
+		{name: "free list loop", damage: func(indexStructure *Index) {
+			indexStructure.setRightPointer(indexStructure.deletedRoot, indexStructure.deletedRoot)
+		}},
+		{name: "run out of range", damage: func(indexStructure *Index) {
+			for x := 0; x < indexStructure.nodeCount(); x++ {
+				if indexStructure.nodeAt(x).status == 'P' {
+					indexStructure.setLeftPointer(x, 99)
+				}
+			}
+		}},
+		{name: "loop in the tree", damage: func(indexStructure *Index) {
+			indexStructure.setRightPointer(indexStructure.indexRoot, indexStructure.indexRoot)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexStructure := testConfigs[0].index()
+			for x, keyInput := range []string{"alphabet", "alps", "beta", "bet", "gamma"} {
+				Insert(keyInput, x, indexStructure)
+			}
+			Delete("gamma", 4, indexStructure)
+			EnableSuffixes(indexStructure)
+			SetWeight("beta", 2, 5, indexStructure)
+			test.damage(indexStructure)
+			var saved bytes.Buffer
+			if err := Save(&saved, indexStructure); err != nil {
+				t.Fatal(err)
+			}
+			var loaded Index
+			Initialise(&loaded)
+			Insert("kept", 7, &loaded)
+			if err := Load(&saved, &loaded); !errors.Is(err, ErrDamaged) {
+				t.Fatalf("Load gave %v, want %v", err, ErrDamaged)
+			}
+			checkIndex(t, &loaded, testModel{{key: "kept", keyNumber: 7}: true})
+		})
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	// whatever byte of a saved index is changed, Load either fails or gives back a structure that passes Verify
+	indexStructure := testConfigs[0].index()
+	for x, keyInput := range []string{"alphabet", "alps", "beta", "bet", "gamma", "alp"} {
+		Insert(keyInput, x, indexStructure)
+	}
+	Delete("gamma", 4, indexStructure)
+	EnableSuffixes(indexStructure)
+	SetWeight("beta", 2, 5, indexStructure)
+	var saved bytes.Buffer
+	if err := Save(&saved, indexStructure); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < saved.Len(); i++ {
+		for _, value := range []byte{0, 1, 'D', 'P', 0x7f, 0xff} {
+			corrupt := bytes.Clone(saved.Bytes())
+			corrupt[i] = value
+			for _, c := range testConfigs {
+				loaded := c.index()
+				if err := Load(bytes.NewReader(corrupt), loaded); err != nil {
+					continue
+				}
+				if valid, faults := Verify(loaded); !valid {
+					t.Fatalf("byte %d set to %#x: Load gave a structure with faults %v", i, value, faults)
+				}
+				Search("", false, loaded)
+				SearchContains("l", loaded)
+				TopK("", 3, loaded)
+			}
+		}
+	}
+}
+
+func TestSaveLoadTailsAndWeights(t *testing.T) {
+	// an index keeping tails and weights keeps them when loaded, and one keeping neither loads without them
+	for _, kept := range []bool{false, true} {
+		t.Run(fmt.Sprint("kept ", kept), func(t *testing.T) {
+			indexStructure := testConfigs[0].index()
+			for x, keyInput := range []string{"alpha", "alps", "beta", "gamma", "alp"} {
+				Insert(keyInput, x, indexStructure)
+			}
+			if kept {
+				EnableSuffixes(indexStructure)
+				SetWeight("beta", 2, 50, indexStructure)
+				SetWeight("alps", 1, -3, indexStructure)
+			}
+			var b bytes.Buffer
+			if err := Save(&b, indexStructure); err != nil {
+				t.Fatal(err)
+			}
+			loaded := testConfigs[1].index()
+			EnableSuffixes(loaded) // whatever the index loaded into kept goes
+			if err := Load(&b, loaded); err != nil {
+				t.Fatal(err)
+			}
+			if (loaded.suffixes != nil) != kept || (loaded.weights != nil) != kept {
+				t.Fatalf("loaded index keeps tails %v and weights %v", loaded.suffixes != nil, loaded.weights != nil)
+			}
+			if _, got := SearchContains("lp", loaded); fmt.Sprint(got) != "[4 0 1]" {
+				t.Errorf("SearchContains gave %v", got)
+			}
+			_, top := TopK("", 2, loaded)
+			_, want := TopK("", 2, indexStructure)
+			if fmt.Sprint(top) != fmt.Sprint(want) {
+				t.Errorf("TopK gave %v, want %v", top, want)
+			}
+			// and go on being kept up to date
+			Insert("help", 9, loaded)
+			if _, got := SearchContains("lp", loaded); fmt.Sprint(got) != "[4 0 1 9]" {
+				t.Errorf("SearchContains after Insert gave %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package key
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type suffixIndex struct {
+	tree    Index            // every tail of every key, filed under the entry's number in "owner"
+	number  map[keyEntry]int // entry to its number in "owner"
+	owner   []keyEntry
+	freeIDs []int
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (s *suffixIndex) insert(keyField string, keyNumber int) {
+	entry := keyEntry{key: keyField, keyNumber: keyNumber}
+	if _, exists := s.number[entry]; exists {
+		return
+	}
+	var id int
+	if len(s.freeIDs) > 0 {
+		id = s.freeIDs[len(s.freeIDs)-1]
+		s.freeIDs = s.freeIDs[:len(s.freeIDs)-1]
+		s.owner[id] = entry
+	} else {
+		id = len(s.owner)
+		s.owner = append(s.owner, entry)
+	}
+	s.number[entry] = id
+	for i := 0; i < len(keyField); i++ { // every byte starts a tail, not only those starting a character
+		Insert(keyField[i:], id, &s.tree)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (s *suffixIndex) delete(keyField string, keyNumber int) {
+	entry := keyEntry{key: keyField, keyNumber: keyNumber}
+	id, exists := s.number[entry]
+	if !exists {
+		return
+	}
+	for i := 0; i < len(keyField); i++ { // every byte starts a tail, not only those starting a character
+		Delete(keyField[i:], id, &s.tree)
+	}
+	delete(s.number, entry)
+	s.owner[id] = keyEntry{}
+	s.freeIDs = append(s.freeIDs, id)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (s *suffixIndex) numbers(ids []int) (indexes []int) {
+	// turns entry numbers into "index-numbers" in key order, each entry once only
+	entries := make([]keyEntry, 0, len(ids))
+	taken := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !taken[id] {
+			taken[id] = true
+			entries = append(entries, s.owner[id])
+		}
+	}
+	sortEntries(entries)
+	for _, entry := range entries {
+		indexes = append(indexes, entry.keyNumber)
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func sortEntries(entries []keyEntry) {
+	// the same order as a global Search -- by key, then duplicates by the decimal form of their "index-number"
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return strconv.Itoa(entries[i].keyNumber) < strconv.Itoa(entries[j].keyNumber)
+	})
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// EnableSuffixes makes the specified index keep a second structure holding every tail of every key, so that
+// SearchSuffix and SearchContains take time related to the number of matches rather than the size of the index
+// the second structure is kept up to date by Insert and Delete from then on -- Save notes that it is kept, and Load
+// builds it again
+//
+func EnableSuffixes(indexStructure *Index) {
+	if indexStructure.suffixes != nil {
+		return
+	}
+	s := &suffixIndex{number: make(map[keyEntry]int)}
+	Initialise(&s.tree)
+	if indexStructure.indexRoot != nullIndexPointer {
+		walk(indexStructure.indexRoot, nil, indexStructure,
+			func(keyPath []byte) (descend, stop bool) {
+				descend = true
+				return
+			},
+			func(keyPath []byte, keyNumbers []int) {
+				for _, keyNumber := range keyNumbers {
+					s.insert(string(keyPath), keyNumber)
+				}
+			})
+	}
+	indexStructure.suffixes = s
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchSuffix returns the index numbers of every key ending with the input string, in ascending key order
+// "matchFound" is "true" if something is located
+//
+func SearchSuffix(keyInput string, indexStructure *Index) (matchFound bool, indexes []int) {
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 {
+		return
+	}
+	if indexStructure.suffixes != nil {
+		var ids []int
+		if matchFound, ids = Search(keyField, true, &indexStructure.suffixes.tree); matchFound {
+			indexes = indexStructure.suffixes.numbers(ids)
+		}
+		return
+	}
+	indexes = scanKeys(func(key string) bool { return strings.HasSuffix(key, keyField) }, indexStructure)
+	matchFound = len(indexes) > 0
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SearchContains returns the index numbers of every key containing the input string, in ascending key order
+// "matchFound" is "true" if something is located
+//
+func SearchContains(keyInput string, indexStructure *Index) (matchFound bool, indexes []int) {
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 {
+		return
+	}
+	if indexStructure.suffixes != nil {
+		var ids []int
+		if matchFound, ids = Search(keyField, false, &indexStructure.suffixes.tree); matchFound {
+			indexes = indexStructure.suffixes.numbers(ids)
+		}
+		return
+	}
+	indexes = scanKeys(func(key string) bool { return strings.Contains(key, keyField) }, indexStructure)
+	matchFound = len(indexes) > 0
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func scanKeys(wanted func(key string) bool, indexStructure *Index) (indexes []int) {
+	// looks at every key -- used when the index keeps no tails
+	if indexStructure.indexRoot == nullIndexPointer {
+		return
+	}
+	walk(indexStructure.indexRoot, nil, indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			descend = true
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			if wanted(string(keyPath)) {
+				indexes = append(indexes, keyNumbers...)
+			}
+		})
+	return
+}
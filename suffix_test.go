@@ -0,0 +1,51 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSearchSuffixContains(t *testing.T) {
+	// the tails and a scan of every key both give what the model holds, as the index changes -- the parts are bytes,
+	// so the tail of "é" that is its last byte is found as well
+	tests := []struct {
+		name, alphabet string
+		parts          []string
+	}{
+		{name: "single bytes", alphabet: "abc", parts: []string{"a", "bc", "cab", "abca", "x"}},
+		{name: "multi-byte", alphabet: "a\xc3\xa9",
+			parts: []string{"\xa9", "\xc3", "\xa9a", "a\xc3", "\xc3\xa9", "\xa9\xc3"}},
+	}
+	for _, c := range testConfigs {
+		for _, test := range tests {
+			t.Run(c.name+"/"+test.name, func(t *testing.T) {
+				r := rand.New(rand.NewSource(1))
+				indexStructure, m := c.index(), testModel{}
+				EnableSuffixes(indexStructure)
+				for x := 0; x < 10; x++ {
+					churn(t, indexStructure, m, r, test.alphabet, 5, 40)
+					scanned := c.index()
+					for entry := range m {
+						Insert(entry.key, entry.keyNumber, scanned)
+					}
+					for _, part := range test.parts {
+						want := m.numbers(func(key string) bool { return strings.HasSuffix(key, part) })
+						for _, index := range []*Index{indexStructure, scanned} {
+							if _, got := SearchSuffix(part, index); fmt.Sprint(got) != fmt.Sprint(want) {
+								t.Fatalf("SearchSuffix(%q) gave %v, want %v", part, got, want)
+							}
+						}
+						want = m.numbers(func(key string) bool { return strings.Contains(key, part) })
+						for _, index := range []*Index{indexStructure, scanned} {
+							if _, got := SearchContains(part, index); fmt.Sprint(got) != fmt.Sprint(want) {
+								t.Fatalf("SearchContains(%q) gave %v, want %v", part, got, want)
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
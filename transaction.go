@@ -0,0 +1,161 @@
+package key
+
+import (
+	"errors"
+	"strings"
+)
+
+// a Transaction never touches the index until Commit -- its Inserts and Deletes are staged, each entry holding
+// whether it should be in the index once the Transaction is committed, and Commit makes them all in one ApplyBatch
+// Search looks at the index as it stands and lays the staged entries over what it finds
+
+// ErrTransactionDone is returned by a Transaction that has already been committed or rolled back
+//
+var ErrTransactionDone = errors.New("key: transaction already finished")
+
+// Transaction is a set of changes to an index made by Insert and Delete that are kept or undone together
+// it is made by Begin and finished by Commit or Rollback
+// nothing is made to the index until Commit, so neither Search on the index nor its observers see the changes
+// before then, and Rollback has nothing to undo -- the index, its free list and its roots are as they were
+// the Transaction does not hide the index from changes made any other way while it is open: its own Search sees
+// them, Rollback leaves them be, and Commit makes its changes on top of them, entry by entry, so an entry it
+// inserts that has been inserted elsewhere in the meantime is simply there, and one it deletes that has gone
+// elsewhere is simply gone
+//
+type Transaction struct {
+	indexStructure *Index
+	staged         map[keyEntry]bool // whether each entry changed should be in the index after Commit
+	done           bool
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Begin opens a Transaction on the specified index -- "err" is always nil, as any number of Transactions can be
+// open at once and each one only changes the index when it is committed
+//
+func Begin(indexStructure *Index) (t *Transaction, err error) {
+	t = &Transaction{indexStructure: indexStructure, staged: make(map[keyEntry]bool)}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Insert stages a key and its "index-number" to be placed into the index when the Transaction is committed
+// a blank key is ignored, and an "index-number" that does not fit the compact layout of the index is not staged and
+// ErrNumberRange is returned
+//
+func (t *Transaction) Insert(keyInput string, keyNumber int) (err error) {
+	if t.done {
+		return ErrTransactionDone
+	}
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 {
+		return
+	}
+	if !t.indexStructure.holdsNumber(keyNumber) {
+		return ErrNumberRange
+	}
+	t.staged[keyEntry{key: keyField, keyNumber: keyNumber}] = true
+	return
+}
+
+// Delete stages a key and its "index-number" to be removed from the index when the Transaction is committed
+//
+func (t *Transaction) Delete(keyInput string, keyNumber int) (err error) {
+	if t.done {
+		return ErrTransactionDone
+	}
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 {
+		return
+	}
+	t.staged[keyEntry{key: keyField, keyNumber: keyNumber}] = false
+	return
+}
+
+// Search looks a key up in the index as it would stand if the Transaction were committed now -- see the Search
+// function
+//
+func (t *Transaction) Search(keyInput string, searchPrecisely bool) (matchFound bool, indexes []int) {
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 && searchPrecisely {
+		return
+	}
+	found := make(map[keyEntry]bool)
+	for _, entry := range searchEntries(keyField, searchPrecisely, t.indexStructure) {
+		found[entry] = true
+	}
+	for entry, present := range t.staged {
+		if entry.key == keyField || (!searchPrecisely && strings.HasPrefix(entry.key, keyField)) {
+			found[entry] = present
+		}
+	}
+	entries := make([]keyEntry, 0, len(found))
+	for entry, present := range found {
+		if present {
+			entries = append(entries, entry)
+		}
+	}
+	sortEntries(entries)
+	for _, entry := range entries {
+		indexes = append(indexes, entry.keyNumber)
+	}
+	matchFound = len(indexes) > 0
+	return
+}
+
+func searchEntries(keyField string, searchPrecisely bool, indexStructure *Index) (entries []keyEntry) {
+	// the keys and "index-numbers" Search finds, in the same order
+	found, keyPointer, keyPath := locate(keyField, indexStructure)
+	if (len(keyField) > 0 && !found) || keyPointer == nullIndexPointer {
+		return
+	}
+	walk(keyPointer, []byte(keyPath), indexStructure,
+		func(keyPath []byte) (descend, stop bool) {
+			descend = !searchPrecisely || len(keyPath) <= len(keyField)
+			return
+		},
+		func(keyPath []byte, keyNumbers []int) {
+			if !searchPrecisely || len(keyPath) == len(keyField) {
+				for _, keyNumber := range keyNumbers {
+					entries = append(entries, keyEntry{key: string(keyPath), keyNumber: keyNumber})
+				}
+			}
+		})
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Commit makes every change staged in the Transaction to the index in one ApplyBatch, telling the observers of the
+// index about each one, and closes the Transaction
+//
+func (t *Transaction) Commit() (err error) {
+	if t.done {
+		return ErrTransactionDone
+	}
+	ops := make([]Op, 0, len(t.staged))
+	for entry, present := range t.staged {
+		ops = append(ops, Op{Delete: !present, Key: entry.key, KeyNumber: entry.keyNumber})
+	}
+	ApplyBatch(ops, t.indexStructure)
+	t.staged, t.done = nil, true
+	return
+}
+
+// Rollback drops every change staged in the Transaction and closes it -- the index was never touched, so the node
+// array, the runs of characters, the free list and the roots are exactly as they were
+//
+func (t *Transaction) Rollback() (err error) {
+	if t.done {
+		return ErrTransactionDone
+	}
+	t.staged, t.done = nil, true
+	return
+}
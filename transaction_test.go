@@ -0,0 +1,170 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	// a Transaction's Search sees the index with its changes laid over, the index itself sees none of them until
+	// Commit, and Rollback leaves every node as it was
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				r := rand.New(rand.NewSource(seed))
+				indexStructure, m := c.index(), testModel{}
+				churn(t, indexStructure, m, r, "abc", 4, 100)
+				var events []Event
+				Observe(func(event Event) { events = append(events, event) }, indexStructure)
+				before := nodesText(t, indexStructure)
+				//
+				tx, _ := Begin(indexStructure)
+				staged := testModel{}
+				for entry := range m {
+					staged[entry] = true
+				}
+				for x := 0; x < 40; x++ {
+					keyInput, keyNumber := randomKey(r, "abc", 4), r.Intn(12)
+					if r.Intn(2) == 0 {
+						tx.Insert(keyInput, keyNumber)
+						staged.insert(keyInput, keyNumber)
+					} else {
+						tx.Delete(keyInput, keyNumber)
+						staged.delete(keyInput, keyNumber)
+					}
+					for _, keyInput := range []string{"", "a", "ab", "abc"} {
+						_, got := tx.Search(keyInput, false)
+						want := staged.numbers(func(key string) bool { return strings.HasPrefix(key, keyInput) })
+						if fmt.Sprint(got) != fmt.Sprint(want) {
+							t.Fatalf("seed %d: Transaction Search(%q) gave %v, want %v", seed, keyInput, got, want)
+						}
+						if keyInput == "" {
+							continue
+						}
+						_, got = tx.Search(keyInput, true)
+						if want = staged.numbers(func(key string) bool { return key == keyInput }); fmt.Sprint(got) !=
+							fmt.Sprint(want) {
+							t.Fatalf("seed %d: Transaction Search(%q, true) gave %v, want %v", seed, keyInput, got,
+								want)
+						}
+					}
+				}
+				if nodesText(t, indexStructure) != before || len(events) != 0 {
+					t.Fatalf("seed %d: the index changed, or its observers were told, before Commit", seed)
+				}
+				//
+				if seed%2 == 0 {
+					if err := tx.Rollback(); err != nil {
+						t.Fatal(err)
+					}
+					if nodesText(t, indexStructure) != before || len(events) != 0 {
+						t.Fatalf("seed %d: Rollback changed the index", seed)
+					}
+					checkIndex(t, indexStructure, m)
+				} else {
+					if err := tx.Commit(); err != nil {
+						t.Fatal(err)
+					}
+					checkIndex(t, indexStructure, staged)
+					if changed := len(symmetricDifference(m, staged)); len(events) != changed {
+						t.Fatalf("seed %d: observers told of %d changes, want %d", seed, len(events), changed)
+					}
+				}
+				if tx.Commit() != ErrTransactionDone || tx.Rollback() != ErrTransactionDone ||
+					tx.Insert("a", 1) != ErrTransactionDone || tx.Delete("a", 1) != ErrTransactionDone {
+					t.Fatalf("seed %d: a finished Transaction did not say so", seed)
+				}
+			}
+		})
+	}
+}
+
+func symmetricDifference(a, b testModel) (changed []keyEntry) {
+	for entry := range a {
+		if !b[entry] {
+			changed = append(changed, entry)
+		}
+	}
+	for entry := range b {
+		if !a[entry] {
+			changed = append(changed, entry)
+		}
+	}
+	return
+}
+
+func TestTransactionOutsideChanges(t *testing.T) {
+	// changes made to the index while a Transaction is open are seen by it, and neither undone nor hidden
+	tests := []struct {
+		name   string
+		inside func(tx *Transaction)
+		commit bool
+		want   []int // filed under "k" at the end
+	}{
+		{name: "rollback keeps outside insert", inside: func(tx *Transaction) { tx.Insert("k", 3) }, want: []int{1, 2}},
+		{name: "commit on top", inside: func(tx *Transaction) { tx.Insert("k", 3) }, commit: true,
+			want: []int{1, 2, 3}},
+		{name: "delete of an outside insert", inside: func(tx *Transaction) { tx.Delete("k", 2) }, commit: true,
+			want: []int{1}},
+		{name: "insert of an outside insert", inside: func(tx *Transaction) { tx.Insert("k", 2) }, commit: true,
+			want: []int{1, 2}},
+		{name: "insert then delete", inside: func(tx *Transaction) { tx.Insert("k", 5); tx.Delete("k", 5) },
+			commit: true, want: []int{1, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var indexStructure Index
+			Initialise(&indexStructure)
+			Insert("k", 1, &indexStructure)
+			tx, err := Begin(&indexStructure)
+			if err != nil {
+				t.Fatal(err)
+			}
+			Insert("k", 2, &indexStructure)
+			if _, got := tx.Search("k", true); fmt.Sprint(got) != "[1 2]" {
+				t.Fatalf("Transaction Search gave %v before any change", got)
+			}
+			test.inside(tx)
+			if test.commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, got := Search("k", true, &indexStructure); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("Search gave %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTransactionsOpenTogether(t *testing.T) {
+	var indexStructure Index
+	Initialise(&indexStructure)
+	first, _ := Begin(&indexStructure)
+	second, err := Begin(&indexStructure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Insert("a", 1)
+	second.Insert("b", 2)
+	if _, got := second.Search("", false); fmt.Sprint(got) != "[2]" {
+		t.Errorf("second Transaction sees %v", got)
+	}
+	first.Commit()
+	if _, got := second.Search("", false); fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("second Transaction sees %v after the first is committed", got)
+	}
+	second.Rollback()
+	if _, got := Search("", false, &indexStructure); fmt.Sprint(got) != "[1]" {
+		t.Errorf("index holds %v", got)
+	}
+	if !errors.Is(first.Rollback(), ErrTransactionDone) {
+		t.Errorf("Rollback after Commit did not fail")
+	}
+}
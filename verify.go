@@ -0,0 +1,216 @@
+package key
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	maxNumberLength = 20 // sign and digits of the longest index-number
+)
+
+const (
+	nodeUnseen = iota
+	nodeInIndex
+	nodeOnFreeList
+)
+
+type verifier struct {
+	indexStructure *Index
+	state          []byte
+	runState       []byte
+	faults         []string
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (v *verifier) fault(format string, args ...interface{}) {
+	v.faults = append(v.faults, fmt.Sprintf(format, args...))
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (v *verifier) check(keyPointer, fromPointer int) bool {
+	// a node may only be reached once and must be inside the node array
+	if keyPointer < 0 || keyPointer >= v.indexStructure.nodeCount() {
+		v.fault("node %d: pointer %d is out of range", fromPointer, keyPointer)
+		return false
+	}
+	if v.state[keyPointer] != nodeUnseen {
+		v.fault("node %d: node %d is reached more than once", fromPointer, keyPointer)
+		return false
+	}
+	v.state[keyPointer] = nodeInIndex
+	return true
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (v *verifier) run(keyPointer int, node indexNode) bool {
+	// a run must have a slot of its own holding at least two characters, the first of them the node's character
+	slot := node.leftPointer
+	if slot < 0 || slot >= len(v.indexStructure.runs) {
+		v.fault("node %d: run %d is out of range", keyPointer, slot)
+		return false
+	}
+	if v.runState[slot] != nodeUnseen {
+		v.fault("node %d: run %d is used more than once", keyPointer, slot)
+		return false
+	}
+	v.runState[slot] = nodeInIndex
+	run := v.indexStructure.runs[slot]
+	if len(run) < 2 || run[0] != node.key {
+		v.fault("node %d: run %q does not suit character %q", keyPointer, run, node.key)
+		return false
+	}
+	return true
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (v *verifier) branch(keyPointer, fromPointer, thread, low, high int, keyPath []byte, duplicate bool) {
+	// walks one branch of the tree -- "thread" is where the last leaf of the branch must point,
+	// "low" and "high" are the character bounds set by the decision nodes above
+	if !v.check(keyPointer, fromPointer) {
+		return
+	}
+	node := v.indexStructure.nodeAt(keyPointer)
+	if node.status != 'D' {
+		if int(node.key) <= low || int(node.key) > high {
+			v.fault("node %d: character %q is outside the range set by its decision nodes", keyPointer, node.key)
+		}
+		if node.status == 'P' {
+			if !v.run(keyPointer, node) {
+				return
+			}
+			keyPath = append(keyPath, v.indexStructure.runs[node.leftPointer]...)
+		} else {
+			keyPath = append(keyPath, node.key)
+		}
+		if duplicate && len(keyPath) > maxNumberLength {
+			v.fault("node %d: duplicate branch is too deep", keyPointer)
+			return
+		}
+		if !duplicate && len(keyPath) > maxKeyLength {
+			v.fault("node %d: key is longer than %d characters", keyPointer, maxKeyLength)
+			return
+		}
+	}
+	//
+	switch node.status {
+	//
+	case 'D':
+		if int(node.key) <= low || int(node.key) >= high {
+			v.fault("node %d: decision character %q is outside the range set by its decision nodes", keyPointer,
+				node.key)
+		}
+		v.branch(node.leftPointer, keyPointer, keyPointer, low, int(node.key), keyPath, duplicate)
+		v.branch(node.rightPointer, keyPointer, thread, int(node.key), high, keyPath, duplicate)
+	//
+	case 'X':
+		if node.leftPointer != nullIndexPointer {
+			v.fault("node %d: character node has a left pointer", keyPointer)
+		}
+		v.branch(node.rightPointer, keyPointer, thread, -1, 255, keyPath, duplicate)
+	//
+	case 'P':
+		if duplicate {
+			v.fault("node %d: duplicate branch contains a run", keyPointer)
+			return
+		}
+		v.branch(node.rightPointer, keyPointer, thread, -1, 255, keyPath, duplicate)
+	//
+	case 'R', 'S':
+		if duplicate && strconv.Itoa(node.leftPointer) != string(keyPath) {
+			v.fault("node %d: duplicate entry %d is filed under %q", keyPointer, node.leftPointer, keyPath)
+		}
+		if node.status == 'R' {
+			v.branch(node.rightPointer, keyPointer, thread, -1, 255, keyPath, duplicate)
+		} else if node.rightPointer != thread {
+			v.fault("node %d: thread points to %d instead of %d", keyPointer, node.rightPointer, thread)
+		}
+	//
+	case 'K', 'L':
+		if duplicate {
+			v.fault("node %d: duplicate branch contains a duplicate", keyPointer)
+			return
+		}
+		v.branch(node.leftPointer, keyPointer, keyPointer, -1, 255, nil, true)
+		if node.status == 'K' {
+			v.branch(node.rightPointer, keyPointer, thread, -1, 255, keyPath, duplicate)
+		} else if node.rightPointer != thread {
+			v.fault("node %d: thread points to %d instead of %d", keyPointer, node.rightPointer, thread)
+		}
+	//
+	default:
+		v.fault("node %d: unknown status %q", keyPointer, node.status)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// Verify checks the internal consistency of an index structure -- every pointer, status, decision character, thread
+// and free-list link is examined and a description of each fault is returned in "faults"
+// "valid" is "true" if no faults are found
+//
+func Verify(indexStructure *Index) (valid bool, faults []string) {
+	v := verifier{
+		indexStructure: indexStructure,
+		state:          make([]byte, indexStructure.nodeCount()),
+		runState:       make([]byte, len(indexStructure.runs)),
+	}
+	//
+	if indexStructure.indexRoot != nullIndexPointer {
+		v.branch(indexStructure.indexRoot, nullIndexPointer, nullIndexPointer, -1, 255, nil, false)
+	}
+	//
+	fromPointer := nullIndexPointer
+	for x := indexStructure.deletedRoot; x != nullIndexPointer; x = indexStructure.nodeAt(x).rightPointer {
+		if x < 0 || x >= indexStructure.nodeCount() {
+			v.fault("node %d: free list pointer %d is out of range", fromPointer, x)
+			break
+		}
+		if v.state[x] == nodeInIndex {
+			v.fault("node %d: node %d is both in the index and on the free list", fromPointer, x)
+			break
+		}
+		if v.state[x] == nodeOnFreeList {
+			v.fault("node %d: free list loops back to node %d", fromPointer, x)
+			break
+		}
+		v.state[x] = nodeOnFreeList
+		fromPointer = x
+	}
+	//
+	for x := range v.state {
+		if v.state[x] == nodeUnseen {
+			v.fault("node %d: node is neither in the index nor on the free list", x)
+		}
+	}
+	for _, slot := range indexStructure.freeRuns {
+		if slot < 0 || slot >= len(v.runState) || v.runState[slot] != nodeUnseen {
+			v.fault("run %d: free run is out of range, in use or freed twice", slot)
+			continue
+		}
+		v.runState[slot] = nodeOnFreeList
+	}
+	for slot := range v.runState {
+		if v.runState[slot] == nodeUnseen {
+			v.fault("run %d: run is neither in use nor free", slot)
+		}
+	}
+	//
+	faults = v.faults
+	valid = len(faults) == 0
+	return
+}
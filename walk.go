@@ -0,0 +1,128 @@
+package key
+
+import (
+	"strings"
+)
+
+type keyEntry struct {
+	key       string
+	keyNumber int
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func trimKey(keyInput string) (keyField string) {
+	// applies the same trimming and truncation as Insert
+	keyField = strings.TrimSpace(keyInput)
+	if len(keyField) >= maxKeyLength {
+		keyField = keyField[0:maxKeyLength]
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func locate(keyField string, indexStructure *Index) (found bool, keyPointer int, keyPath string) {
+	// finds the node holding the last character of "keyField" -- whether or not a key ends there -- along with the
+	// characters above that node, which are fewer than all but the last of "keyField" when it ends inside a run
+	keyPointer = indexStructure.indexRoot
+	if len(keyField) == 0 {
+		return
+	}
+	i := 0
+	for keyPointer != nullIndexPointer {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			if keyField[i] <= node.key {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		characters := indexStructure.characters(node)
+		length := min(len(characters), len(keyField)-i)
+		if keyField[i:i+length] != characters[:length] {
+			return
+		}
+		if i+length == len(keyField) {
+			found, keyPath = true, keyField[:i]
+			return
+		}
+		if node.status == 'S' || node.status == 'L' { // at the terminal leaf
+			return
+		}
+		keyPointer = node.rightPointer
+		i += length
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func duplicateNumbers(keyPointer int, indexStructure *Index, keyNumbers []int) []int {
+	// appends the "index-numbers" held in a duplicate branch in ascending order
+	node := indexStructure.nodeAt(keyPointer)
+	switch node.status {
+	case 'D':
+		keyNumbers = duplicateNumbers(node.leftPointer, indexStructure, keyNumbers)
+		keyNumbers = duplicateNumbers(node.rightPointer, indexStructure, keyNumbers)
+	case 'X':
+		keyNumbers = duplicateNumbers(node.rightPointer, indexStructure, keyNumbers)
+	case 'R':
+		keyNumbers = append(keyNumbers, node.leftPointer)
+		keyNumbers = duplicateNumbers(node.rightPointer, indexStructure, keyNumbers)
+	case 'S':
+		keyNumbers = append(keyNumbers, node.leftPointer)
+	}
+	return keyNumbers
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func walk(keyPointer int, keyPath []byte, indexStructure *Index,
+	enter func(keyPath []byte) (descend, stop bool), visit func(keyPath []byte, keyNumbers []int)) (stopped bool) {
+	// visits the keys below "keyPointer" in ascending order -- "keyPath" holds the characters above it
+	// "enter" is given the key so far at each character node and says whether to look at that key and the branch
+	// below it, or to stop the walk altogether -- "visit" is given each key reached with its "index-numbers"
+	for keyPointer != nullIndexPointer {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			if walk(node.leftPointer, keyPath, indexStructure, enter, visit) {
+				return true
+			}
+			keyPointer = node.rightPointer
+			continue
+		}
+		characters := indexStructure.characters(node)
+		for i := 0; i < len(characters); i++ { // a run is entered one character at a time
+			keyPath = append(keyPath, characters[i])
+			descend, stop := enter(keyPath)
+			if stop {
+				return true
+			}
+			if !descend {
+				return false
+			}
+		}
+		switch node.status {
+		case 'R', 'S':
+			visit(keyPath, []int{node.leftPointer})
+		case 'K', 'L':
+			visit(keyPath, duplicateNumbers(node.leftPointer, indexStructure, nil))
+		}
+		if node.status == 'S' || node.status == 'L' {
+			return false
+		}
+		keyPointer = node.rightPointer
+	}
+	return false
+}
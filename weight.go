@@ -0,0 +1,310 @@
+package key
+
+import (
+	"container/heap"
+	"math"
+	"strconv"
+)
+
+const noWeight = math.MinInt // best weight of an empty branch
+
+type weightIndex struct {
+	weight map[keyEntry]int // weights that have been set -- anything else weighs 0
+	best   []int            // highest weight anywhere below each node, following the node array
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// TopMatch is one entry returned by TopK
+//
+type TopMatch struct {
+	Key       string
+	KeyNumber int
+	Weight    int
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (w *weightIndex) forget(keyField string, keyNumber int) {
+	delete(w.weight, keyEntry{key: keyField, keyNumber: keyNumber})
+}
+
+func (w *weightIndex) copy(to, from int) {
+	// gives a node made when a run is opened the best weight of the run
+	for len(w.best) <= to {
+		w.best = append(w.best, noWeight)
+	}
+	w.best[to] = w.best[from]
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (w *weightIndex) measure(keyPointer int, keyField string, indexStructure *Index) {
+	// works out the best weight of one node from the nodes directly below it -- "keyField" is the key so far
+	node := indexStructure.nodeAt(keyPointer)
+	best := noWeight
+	switch node.status {
+	case 'D':
+		best = max(w.best[node.leftPointer], w.best[node.rightPointer])
+	case 'X', 'P':
+		best = w.best[node.rightPointer]
+	case 'R', 'S':
+		best = w.weight[keyEntry{key: keyField, keyNumber: node.leftPointer}]
+		if node.status == 'R' {
+			best = max(best, w.best[node.rightPointer])
+		}
+	case 'K', 'L':
+		best = w.best[node.leftPointer]
+		if node.status == 'K' {
+			best = max(best, w.best[node.rightPointer])
+		}
+	}
+	w.best[keyPointer] = best
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (w *weightIndex) measureAll(keyPointer int, keyPath []byte, duplicateKey string, indexStructure *Index) {
+	// works out the best weight of every node below "keyPointer" -- inside a duplicate branch the characters are
+	// digits of the "index-number" and "duplicateKey" is the key the branch belongs to
+	node := indexStructure.nodeAt(keyPointer)
+	if node.status != 'D' && duplicateKey == "" {
+		keyPath = append(keyPath, indexStructure.characters(node)...)
+	}
+	if node.status == 'D' || node.status == 'K' || node.status == 'L' {
+		if node.status != 'D' {
+			w.measureAll(node.leftPointer, nil, string(keyPath), indexStructure)
+		} else {
+			w.measureAll(node.leftPointer, keyPath, duplicateKey, indexStructure)
+		}
+	}
+	if node.status == 'D' || node.status == 'X' || node.status == 'P' || node.status == 'R' || node.status == 'K' {
+		w.measureAll(node.rightPointer, keyPath, duplicateKey, indexStructure)
+	}
+	if duplicateKey != "" {
+		w.measure(keyPointer, duplicateKey, indexStructure)
+	} else {
+		w.measure(keyPointer, string(keyPath), indexStructure)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func (w *weightIndex) refresh(keyField string, indexStructure *Index) {
+	// only the nodes on the way down to "keyField", and its duplicate branch, can have changed after an Insert or
+	// Delete of that key, so only they are worked out again -- from the bottom up
+	for len(w.best) < indexStructure.nodeCount() {
+		w.best = append(w.best, noWeight)
+	}
+	var path, keyLengths []int // nodes on the way down and the length of the key so far at each
+	keyPointer := indexStructure.indexRoot
+	i := 0
+	for keyPointer != nullIndexPointer {
+		node := indexStructure.nodeAt(keyPointer)
+		if node.status == 'D' {
+			path = append(path, keyPointer)
+			keyLengths = append(keyLengths, i)
+			if keyField[i] <= node.key {
+				keyPointer = node.leftPointer
+			} else {
+				keyPointer = node.rightPointer
+			}
+			continue
+		}
+		if keyField[i] != node.key {
+			break
+		}
+		if node.status == 'P' {
+			run := indexStructure.runs[node.leftPointer]
+			path = append(path, keyPointer)
+			keyLengths = append(keyLengths, i)
+			if len(keyField)-i <= len(run) || keyField[i:i+len(run)] != run {
+				break
+			}
+			keyPointer = node.rightPointer
+			i += len(run)
+			continue
+		}
+		path = append(path, keyPointer)
+		keyLengths = append(keyLengths, i+1)
+		if i+1 == len(keyField) {
+			if node.status == 'K' || node.status == 'L' {
+				w.measureAll(node.leftPointer, nil, keyField, indexStructure)
+			}
+			break
+		}
+		if node.status == 'S' || node.status == 'L' {
+			break
+		}
+		keyPointer = node.rightPointer
+		i++
+	}
+	//
+	for x := len(path) - 1; x >= 0; x-- {
+		w.measure(path[x], keyField[:keyLengths[x]], indexStructure)
+	}
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func weigh(weight map[keyEntry]int, indexStructure *Index) {
+	// makes the index keep the best weight below every node, starting from the weights given
+	w := &weightIndex{weight: weight, best: make([]int, indexStructure.nodeCount())}
+	if indexStructure.indexRoot != nullIndexPointer {
+		w.measureAll(indexStructure.indexRoot, nil, "", indexStructure)
+	}
+	indexStructure.weights = w
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// SetWeight gives an entry of the specified index a weight for TopK -- entries that have not been given one weigh 0
+// the first call of SetWeight or TopK makes the index keep the best weight below every node, which Insert and Delete
+// then maintain -- Save writes the weights and Load gives them back
+//
+func SetWeight(keyInput string, keyNumber, weight int, indexStructure *Index) {
+	keyField := trimKey(keyInput)
+	if len(keyField) == 0 {
+		return
+	}
+	if indexStructure.weights == nil {
+		weigh(make(map[keyEntry]int), indexStructure)
+	}
+	indexStructure.weights.weight[keyEntry{key: keyField, keyNumber: keyNumber}] = weight
+	indexStructure.weights.refresh(keyField, indexStructure)
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+type topItem struct {
+	weight     int
+	keyPath    string
+	digits     string // digits above a node in a duplicate branch, or the decimal form of an entry's number
+	keyPointer int    // branch still to be opened, or nullIndexPointer for an entry
+	duplicate  bool   // "keyPointer" is inside the duplicate branch of "keyPath"
+	keyNumber  int
+}
+
+type topQueue []topItem
+
+func (q topQueue) Len() int { return len(q) }
+
+func (q topQueue) Less(i, j int) bool {
+	// heaviest first, then in key order -- a branch of the tree holds only keys longer than its "keyPath", while the
+	// entries of a key and its duplicate branch are ordered by the digits of their "index-numbers"
+	if q[i].weight != q[j].weight {
+		return q[i].weight > q[j].weight
+	}
+	if q[i].keyPath != q[j].keyPath {
+		return q[i].keyPath < q[j].keyPath
+	}
+	longerI := q[i].keyPointer != nullIndexPointer && !q[i].duplicate
+	longerJ := q[j].keyPointer != nullIndexPointer && !q[j].duplicate
+	if longerI != longerJ {
+		return longerJ
+	}
+	if q[i].digits != q[j].digits {
+		return q[i].digits < q[j].digits
+	}
+	return q[i].keyPointer == nullIndexPointer && q[j].keyPointer != nullIndexPointer
+}
+
+func (q topQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *topQueue) Push(x interface{}) { *q = append(*q, x.(topItem)) }
+
+func (q *topQueue) Pop() interface{} {
+	item := (*q)[len(*q)-1]
+	*q = (*q)[:len(*q)-1]
+	return item
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+// TopK returns the "k" heaviest entries whose keys start with the input string, heaviest first, with entries of equal
+// weight in key order -- a blank input string looks at the whole index
+// branches are opened heaviest first using the best weight kept below each node, so light branches are never walked
+// "matchFound" is "true" if something is located
+//
+func TopK(keyPrefix string, k int, indexStructure *Index) (matchFound bool, matches []TopMatch) {
+	keyField := trimKey(keyPrefix)
+	keyPointer := indexStructure.indexRoot
+	if len(keyField) > 0 {
+		var found bool
+		if found, keyPointer, keyField = locate(keyField, indexStructure); !found {
+			return
+		}
+	}
+	if keyPointer == nullIndexPointer || k <= 0 {
+		return
+	}
+	if indexStructure.weights == nil { // nothing weighed yet so every entry weighs 0
+		weigh(make(map[keyEntry]int), indexStructure)
+	}
+	w := indexStructure.weights
+	//
+	queue := topQueue{{weight: w.best[keyPointer], keyPath: keyField, keyPointer: keyPointer}}
+	for len(queue) > 0 && len(matches) < k {
+		item := heap.Pop(&queue).(topItem)
+		if item.keyPointer == nullIndexPointer {
+			matches = append(matches, TopMatch{Key: item.keyPath, KeyNumber: item.keyNumber, Weight: item.weight})
+			continue
+		}
+		node := indexStructure.nodeAt(item.keyPointer)
+		keyPath, digits := item.keyPath, item.digits
+		if node.status != 'D' {
+			if item.duplicate {
+				digits += string([]byte{node.key})
+			} else {
+				keyPath += indexStructure.characters(node)
+			}
+		}
+		branch := func(keyPointer int, duplicate bool) {
+			opened := topItem{weight: w.best[keyPointer], keyPath: keyPath, keyPointer: keyPointer,
+				duplicate: duplicate}
+			if duplicate {
+				opened.digits = digits
+			}
+			heap.Push(&queue, opened)
+		}
+		switch node.status {
+		case 'D':
+			branch(node.leftPointer, item.duplicate)
+			branch(node.rightPointer, item.duplicate)
+		case 'X', 'P':
+			branch(node.rightPointer, item.duplicate)
+		case 'R', 'S':
+			heap.Push(&queue, topItem{weight: w.weight[keyEntry{key: keyPath, keyNumber: node.leftPointer}],
+				keyPath: keyPath, digits: strconv.Itoa(node.leftPointer), keyPointer: nullIndexPointer,
+				keyNumber: node.leftPointer})
+			if node.status == 'R' {
+				branch(node.rightPointer, item.duplicate)
+			}
+		case 'K', 'L':
+			branch(node.leftPointer, true)
+			if node.status == 'K' {
+				branch(node.rightPointer, false)
+			}
+		}
+	}
+	matchFound = len(matches) > 0
+	return
+}
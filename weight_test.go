@@ -0,0 +1,157 @@
+package key
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func topModel(keyPrefix string, k int, m testModel, weight map[keyEntry]int) (matches []TopMatch) {
+	// every entry under the prefix, heaviest first and then in key order, cut to "k"
+	entries := m.entries()
+	sort.SliceStable(entries, func(i, j int) bool { return weight[entries[i]] > weight[entries[j]] })
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.key, trimKey(keyPrefix)) && len(matches) < k {
+			matches = append(matches, TopMatch{Key: entry.key, KeyNumber: entry.keyNumber, Weight: weight[entry]})
+		}
+	}
+	return
+}
+
+//
+/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-/////////-
+//
+
+func TestTopK(t *testing.T) {
+	indexStructure, m := patternIndex("b", "a", "ab", "ab", "abc", "ab", "b")
+	weight := map[keyEntry]int{}
+	for _, w := range []struct {
+		key               string
+		keyNumber, weight int
+	}{
+		{"ab", 2, 7}, {"abc", 4, 7}, {"b", 0, -1}, {"ab", 5, 3}, {"zz", 9, 100}, {"  ", 1, 100},
+	} {
+		SetWeight(w.key, w.keyNumber, w.weight, indexStructure)
+		if m[keyEntry{key: w.key, keyNumber: w.keyNumber}] {
+			weight[keyEntry{key: w.key, keyNumber: w.keyNumber}] = w.weight
+		}
+	}
+	tests := []struct {
+		keyPrefix string
+		k         int
+	}{
+		{"", 1}, {"", 3}, {"", 100}, {"a", 2}, {" ab ", 4}, {"abc", 1}, {"b", 3}, {"zz", 3}, {"x", 1}, {"", 0},
+		{"", -1},
+	}
+	for _, test := range tests {
+		want := topModel(test.keyPrefix, test.k, m, weight)
+		if matchFound, got := TopK(test.keyPrefix, test.k, indexStructure); matchFound != (len(want) > 0) ||
+			fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("TopK(%q, %d) gave %v %v, want %v", test.keyPrefix, test.k, matchFound, got, want)
+		}
+	}
+	// a weight set before the entry is inserted counts once it is
+	Insert("zz", 9, indexStructure)
+	if _, got := TopK("", 1, indexStructure); fmt.Sprint(got) != fmt.Sprint([]TopMatch{{"zz", 9, 100}}) {
+		t.Errorf("TopK after inserting zz gave %v", got)
+	}
+	// and a deleted entry loses its weight
+	Delete("zz", 9, indexStructure)
+	Insert("zz", 9, indexStructure)
+	if _, got := TopK("zz", 1, indexStructure); fmt.Sprint(got) != fmt.Sprint([]TopMatch{{"zz", 9, 0}}) {
+		t.Errorf("TopK after inserting zz again gave %v", got)
+	}
+}
+
+func TestTopKAgainstModel(t *testing.T) {
+	// every way of changing an index keeps the best weights below its nodes right, checked by TopK after each change
+	for _, c := range testConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			indexStructure, m := c.index(), testModel{}
+			weight := map[keyEntry]int{}
+			deleted := func(entry keyEntry) {
+				delete(m, entry)
+				delete(weight, entry)
+			}
+			randomEntry := func() keyEntry {
+				return keyEntry{key: randomKey(r, "abc", 4), keyNumber: r.Intn(40) - 5}
+			}
+			for x := 0; x < 400; x++ {
+				var operation string
+				switch n := r.Intn(20); {
+				case n < 6:
+					operation = "insert"
+					entry := randomEntry()
+					Insert(entry.key, entry.keyNumber, indexStructure)
+					m.insert(entry.key, entry.keyNumber)
+				case n < 9 && len(m) > 0:
+					operation = "delete"
+					entry := m.entries()[r.Intn(len(m))]
+					Delete(entry.key, entry.keyNumber, indexStructure)
+					deleted(entry)
+				case n < 14 && len(m) > 0:
+					operation = "weight"
+					entry := m.entries()[r.Intn(len(m))]
+					weight[entry] = r.Intn(11) - 5
+					SetWeight(entry.key, entry.keyNumber, weight[entry], indexStructure)
+				case n < 16:
+					operation = "batch"
+					ops := make([]Op, r.Intn(20))
+					for y := range ops {
+						entry := randomEntry()
+						ops[y] = Op{Delete: r.Intn(2) == 0, Key: entry.key, KeyNumber: entry.keyNumber}
+					}
+					ApplyBatch(ops, indexStructure)
+					for _, op := range ops {
+						if m.apply(op, indexStructure) == OpDeleted {
+							delete(weight, keyEntry{key: op.Key, keyNumber: op.KeyNumber})
+						}
+					}
+				case n < 19:
+					operation = "transaction"
+					transaction, _ := Begin(indexStructure)
+					staged := map[keyEntry]bool{}
+					for y := r.Intn(10); y > 0; y-- {
+						entry := randomEntry()
+						staged[entry] = r.Intn(2) == 0
+						if staged[entry] {
+							transaction.Insert(entry.key, entry.keyNumber)
+						} else {
+							transaction.Delete(entry.key, entry.keyNumber)
+						}
+					}
+					if r.Intn(3) == 0 {
+						transaction.Rollback()
+						break
+					}
+					transaction.Commit()
+					for entry, present := range staged {
+						if present {
+							m.insert(entry.key, entry.keyNumber)
+						} else {
+							deleted(entry)
+						}
+					}
+				default:
+					operation = "balance"
+					if indexStructure.indexRoot != nullIndexPointer {
+						indexStructure.balanceAll(nullIndexPointer)
+					}
+				}
+				for _, keyPrefix := range []string{"", "a", "ab", "b", "cab", "abca"} {
+					for _, k := range []int{1, 3, 1000} {
+						want := topModel(keyPrefix, k, m, weight)
+						if _, got := TopK(keyPrefix, k, indexStructure); fmt.Sprint(got) != fmt.Sprint(want) {
+							t.Fatalf("after %s %d: TopK(%q, %d) gave %v, want %v", operation, x, keyPrefix, k, got,
+								want)
+						}
+					}
+				}
+			}
+			checkIndex(t, indexStructure, m)
+		})
+	}
+}